@@ -0,0 +1,266 @@
+package v1alpha1
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TLSMode defines how the TLS section of the result ingress is built.
+type TLSMode string
+
+const (
+	// SourceTLSMode copies the TLS sections of the source ingresses.
+	SourceTLSMode TLSMode = "Source"
+	// WildcardTLSMode creates a single wildcard TLS section covering the
+	// hosts of the source ingresses.
+	WildcardTLSMode TLSMode = "Wildcard"
+)
+
+// PackingStrategy defines how source ingresses are placed into result
+// ingresses.
+type PackingStrategy string
+
+const (
+	// FirstFitPacking fills the result ingresses one after the other.
+	FirstFitPacking PackingStrategy = "FirstFit"
+	// BestFitDecreasingPacking places the biggest source ingresses first,
+	// each into the fullest result ingress it fits.
+	BestFitDecreasingPacking PackingStrategy = "BestFitDecreasing"
+	// LabelAffinityPacking keeps the source ingresses whose namespaces have
+	// the same value of a label in the same result ingress when they fit.
+	LabelAffinityPacking PackingStrategy = "LabelAffinity"
+)
+
+// SlotCounter defines how the slots used by a source ingress are counted.
+type SlotCounter string
+
+const (
+	// GCESlotCounter counts the path rules of a GCE URL map.
+	GCESlotCounter SlotCounter = "GCE"
+	// ALBSlotCounter counts the listener rules of an AWS application load
+	// balancer, one per path on every listener of the result ingress, and
+	// limits its target groups and certificates.
+	ALBSlotCounter SlotCounter = "AWSALB"
+	// UnlimitedSlotCounter merges all the source ingresses into a single
+	// result ingress.
+	UnlimitedSlotCounter SlotCounter = "Unlimited"
+)
+
+const (
+	// ReadyCondition tells whether the source ingresses have been merged.
+	ReadyCondition = "Ready"
+)
+
+// IngressMergeTLS configures the TLS section of the result ingress.
+type IngressMergeTLS struct {
+	// Mode is either Source or Wildcard, defaults to Source.
+	// +kubebuilder:validation:Enum=Source;Wildcard
+	// +optional
+	Mode TLSMode `json:"mode,omitempty"`
+
+	// WildcardIgnoreSelector selects source ingresses left out of the
+	// wildcard TLS section.
+	// +optional
+	WildcardIgnoreSelector *metav1.LabelSelector `json:"wildcardIgnoreSelector,omitempty"`
+}
+
+// IngressMergePacking configures how source ingresses are placed into
+// result ingresses.
+type IngressMergePacking struct {
+	// Strategy is one of FirstFit, BestFitDecreasing or LabelAffinity,
+	// defaults to FirstFit.
+	// +kubebuilder:validation:Enum=FirstFit;BestFitDecreasing;LabelAffinity
+	// +optional
+	Strategy PackingStrategy `json:"strategy,omitempty"`
+
+	// AffinityLabel is the label of the namespaces grouping source ingresses
+	// with the LabelAffinity strategy.
+	// +optional
+	AffinityLabel string `json:"affinityLabel,omitempty"`
+}
+
+// IngressMergeLimits caps the resources of a result ingress that providers
+// limit besides the slots. Unset limits are not enforced.
+type IngressMergeLimits struct {
+	// Paths is the maximum number of paths.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Paths int32 `json:"paths,omitempty"`
+
+	// Hosts is the maximum number of distinct hosts.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Hosts int32 `json:"hosts,omitempty"`
+
+	// Certificates is the maximum number of distinct TLS secrets.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Certificates int32 `json:"certificates,omitempty"`
+
+	// Backends is the maximum number of distinct service backends.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Backends int32 `json:"backends,omitempty"`
+}
+
+// IngressMergeSpec defines the desired state of IngressMerge
+type IngressMergeSpec struct {
+	// Name of the result ingress, additional result ingresses are suffixed
+	// with an ordinal. Defaults to the name of the IngressMerge.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Labels applied to the result ingress.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations applied to the result ingress.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// DefaultBackend of the result ingress.
+	// +optional
+	DefaultBackend *networkingv1.IngressBackend `json:"defaultBackend,omitempty"`
+
+	// IngressClassName of the result ingress.
+	// +optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+
+	// TLS configures the TLS section of the result ingress.
+	// +optional
+	TLS IngressMergeTLS `json:"tls,omitempty"`
+
+	// MaxSlots overrides the number of slots of a result ingress.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxSlots *int32 `json:"maxSlots,omitempty"`
+
+	// Packing configures how source ingresses are placed into result
+	// ingresses.
+	// +optional
+	Packing IngressMergePacking `json:"packing,omitempty"`
+
+	// SlotCounter is one of GCE, AWSALB or Unlimited, defaults to the slot
+	// counter of the controller.
+	// +kubebuilder:validation:Enum=GCE;AWSALB;Unlimited
+	// +optional
+	SlotCounter SlotCounter `json:"slotCounter,omitempty"`
+
+	// Limits caps the resources of a result ingress besides the slots.
+	// +optional
+	Limits IngressMergeLimits `json:"limits,omitempty"`
+
+	// IngressSelector restricts the source ingresses merged by this resource.
+	// +optional
+	IngressSelector *metav1.LabelSelector `json:"ingressSelector,omitempty"`
+}
+
+// ResultIngressStatus describes a result ingress and the source ingresses
+// merged into it.
+type ResultIngressStatus struct {
+	// Name of the result ingress.
+	Name string `json:"name"`
+
+	// Sources are the names of the source ingresses merged into the result
+	// ingress.
+	// +optional
+	Sources []string `json:"sources,omitempty"`
+
+	// FreeSlots is the number of slots of the result ingress left for other
+	// source ingresses.
+	FreeSlots int32 `json:"freeSlots"`
+}
+
+// SkippedIngress is a source ingress left out of the merge.
+type SkippedIngress struct {
+	// Name of the source ingress.
+	Name string `json:"name"`
+
+	// Reason is the reason of the warning event emitted on the source
+	// ingress.
+	Reason string `json:"reason"`
+}
+
+// PathConflictStatus is a path of a source ingress left out of the merge,
+// another source ingress declaring the same host, path and path type.
+type PathConflictStatus struct {
+	// Name of the source ingress whose path is left out.
+	Name string `json:"name"`
+
+	// Winner is the name of the source ingress keeping the path.
+	Winner string `json:"winner"`
+
+	// Host of the path.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Path left out.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// PathType of the path.
+	// +optional
+	PathType networkingv1.PathType `json:"pathType,omitempty"`
+}
+
+// IngressMergeStatus defines the observed state of IngressMerge
+type IngressMergeStatus struct {
+	// ObservedGeneration is the generation of the spec last merged.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ResultIngresses are the names of the result ingresses.
+	// +optional
+	ResultIngresses []string `json:"resultIngresses,omitempty"`
+
+	// Buckets describe the result ingresses and their source ingresses.
+	// +optional
+	Buckets []ResultIngressStatus `json:"buckets,omitempty"`
+
+	// SkippedIngresses are the source ingresses referencing this
+	// configuration that are left out of the merge.
+	// +optional
+	SkippedIngresses []SkippedIngress `json:"skippedIngresses,omitempty"`
+
+	// PathConflicts are the paths of source ingresses left out of the merge
+	// as they are declared by other source ingresses taking precedence.
+	// +optional
+	PathConflicts []PathConflictStatus `json:"pathConflicts,omitempty"`
+
+	// LastReconcileTime is the time of the last merge changing the status.
+	// +optional
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	// LastError is the error of the last merge, if any.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// Conditions of the merge.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// IngressMerge is the Schema for the ingressmerges API
+type IngressMerge struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IngressMergeSpec   `json:"spec,omitempty"`
+	Status IngressMergeStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// IngressMergeList contains a list of IngressMerge
+type IngressMergeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IngressMerge `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IngressMerge{}, &IngressMergeList{})
+}
@@ -0,0 +1,173 @@
+package ingress_merge
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// FieldManager is the field manager the result ingresses are applied
+	// with.
+	FieldManager = "ingress-merge"
+
+	// UpdateFieldManager is the field manager of the other writes of the
+	// controller, like the empty-since annotation or the status of configs.
+	// Written with the default field manager, which is the binary name too,
+	// their fields would be taken for applied ones.
+	UpdateFieldManager = "ingress-merge-update"
+)
+
+// createResultIngress creates a result ingress. Unlike applying, creating
+// fails when an ingress of the same name exists instead of taking it over.
+// The fields it sets are owned by the update operation of the field manager
+// until the first apply upgrades them, see upgradeManagedFields.
+func (r *IngressReconciler) createResultIngress(ctx context.Context, ingress *networkingv1.Ingress) error {
+	ingress.ResourceVersion = ""
+	ingress.ManagedFields = nil
+
+	return r.Create(ctx, ingress, client.FieldOwner(FieldManager))
+}
+
+// applyResultIngress updates the existing result ingress with server-side
+// apply. The controller owns only the fields it sets, so the labels and
+// annotations other controllers add to the result ingress are kept, while
+// the ones it stops setting are removed. Fields set by others on what the
+// controller sets are taken over.
+func (r *IngressReconciler) applyResultIngress(ctx context.Context, ingress, existing *networkingv1.Ingress) error {
+	if err := r.upgradeManagedFields(ctx, existing); err != nil {
+		return err
+	}
+
+	ingress.APIVersion = networkingv1.SchemeGroupVersion.String()
+	ingress.Kind = "Ingress"
+	ingress.ResourceVersion = ""
+	ingress.ManagedFields = nil
+
+	return r.Patch(ctx, ingress, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+}
+
+// upgradeManagedFields moves the fields owned by the update operation of the
+// field manager to its apply operation, as client-go's csaupgrade does. They
+// are owned by updates when the controller created the result ingress, or
+// updated it before using server-side apply; the apply operation would
+// otherwise never remove them, as they would stay owned by the updates.
+func (r *IngressReconciler) upgradeManagedFields(ctx context.Context, ingress *networkingv1.Ingress) error {
+	managedFields, upgraded := upgradedManagedFields(ingress.ManagedFields)
+	if !upgraded {
+		return nil
+	}
+
+	// replacing the resource version fails when the ingress changed since
+	patch, err := json.Marshal([]map[string]interface{}{
+		{"op": "replace", "path": "/metadata/managedFields", "value": managedFields},
+		{"op": "replace", "path": "/metadata/resourceVersion", "value": ingress.ResourceVersion},
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.Patch(ctx, ingress, client.RawPatch(types.JSONPatchType, patch))
+}
+
+// upgradedManagedFields returns the managed fields with the ones of the
+// update operation of the field manager merged into its apply operation, and
+// whether there were any.
+func upgradedManagedFields(entries []metaV1.ManagedFieldsEntry) ([]metaV1.ManagedFieldsEntry, bool) {
+	updated := map[string]interface{}{}
+	upgraded := []metaV1.ManagedFieldsEntry{}
+	applied := -1
+
+	for _, entry := range entries {
+		if entry.Manager != FieldManager || entry.Operation != metaV1.ManagedFieldsOperationUpdate {
+			if entry.Manager == FieldManager && entry.Operation == metaV1.ManagedFieldsOperationApply {
+				applied = len(upgraded)
+			}
+			upgraded = append(upgraded, entry)
+			continue
+		}
+
+		mergeFieldSets(updated, entryFields(entry))
+	}
+
+	if len(upgraded) == len(entries) {
+		return entries, false
+	}
+
+	if applied < 0 {
+		applied = len(upgraded)
+		upgraded = append(upgraded, metaV1.ManagedFieldsEntry{
+			Manager:    FieldManager,
+			Operation:  metaV1.ManagedFieldsOperationApply,
+			APIVersion: networkingv1.SchemeGroupVersion.String(),
+			FieldsType: "FieldsV1",
+		})
+	}
+
+	fields := entryFields(upgraded[applied])
+	mergeFieldSets(fields, updated)
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return entries, false
+	}
+
+	now := metaV1.Now()
+	upgraded[applied].Time = &now
+	upgraded[applied].FieldsV1 = &metaV1.FieldsV1{Raw: raw}
+
+	return upgraded, true
+}
+
+func entryFields(entry metaV1.ManagedFieldsEntry) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if entry.FieldsV1 != nil {
+		_ = json.Unmarshal(entry.FieldsV1.Raw, &fields)
+	}
+
+	return fields
+}
+
+// mergeFieldSets adds the fields of a managed fields set to another one.
+func mergeFieldSets(into, from map[string]interface{}) {
+	for key, value := range from {
+		nested, isSet := value.(map[string]interface{})
+		existing, exists := into[key].(map[string]interface{})
+		if isSet && exists {
+			mergeFieldSets(existing, nested)
+			continue
+		}
+
+		if _, exists := into[key]; !exists {
+			into[key] = value
+		}
+	}
+}
+
+// appliedKeys returns the keys of the labels or annotations of an ingress
+// owned by the field manager of the controller, field being "labels" or
+// "annotations". The keys owned by its update operation are included, as
+// they are upgraded to the apply operation before the next apply.
+func appliedKeys(ingress *networkingv1.Ingress, field string) map[string]bool {
+	keys := make(map[string]bool)
+
+	for _, entry := range ingress.ManagedFields {
+		if entry.Manager != FieldManager {
+			continue
+		}
+
+		metadata, _ := entryFields(entry)["f:metadata"].(map[string]interface{})
+		owned, _ := metadata["f:"+field].(map[string]interface{})
+		for key := range owned {
+			if strings.HasPrefix(key, "f:") {
+				keys[strings.TrimPrefix(key, "f:")] = true
+			}
+		}
+	}
+
+	return keys
+}
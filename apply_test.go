@@ -0,0 +1,231 @@
+package ingress_merge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// applyClient stands in for server-side apply of ingresses, which the fake
+// client does not support, by creating or replacing the applied ingress but
+// its status, a subresource. How the API server merges the applied fields
+// with the ones of other field managers is not emulated, appliedKeys and
+// upgradedManagedFields are tested on their own.
+type applyClient struct {
+	client.Client
+}
+
+func (c applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	patchOptions := &client.PatchOptions{}
+	patchOptions.ApplyOptions(opts)
+
+	if patch.Type() != types.ApplyPatchType || len(patchOptions.DryRun) > 0 {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	existing := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if k8sErrors.IsNotFound(err) {
+		return c.Client.Create(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+
+	if ingress, ok := obj.(*networkingv1.Ingress); ok {
+		ingress.Status = existing.(*networkingv1.Ingress).Status
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+
+	return c.Client.Update(ctx, obj)
+}
+
+func TestAppliedKeys(t *testing.T) {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			ManagedFields: []metaV1.ManagedFieldsEntry{
+				{
+					Manager:    FieldManager,
+					Operation:  metaV1.ManagedFieldsOperationApply,
+					FieldsType: "FieldsV1",
+					FieldsV1: &metaV1.FieldsV1{Raw: []byte(`{
+						"f:metadata": {
+							"f:annotations": {".": {}, "f:merge.ingress.kubernetes.io/result": {}},
+							"f:labels": {"f:team": {}}
+						},
+						"f:spec": {"f:rules": {}}
+					}`)},
+				},
+				{
+					Manager:    "external-dns",
+					Operation:  metaV1.ManagedFieldsOperationUpdate,
+					FieldsType: "FieldsV1",
+					FieldsV1:   &metaV1.FieldsV1{Raw: []byte(`{"f:metadata": {"f:annotations": {"f:external-dns": {}}}}`)},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, map[string]bool{ResultAnnotation: true}, appliedKeys(ingress, "annotations"))
+	assert.Equal(t, map[string]bool{"team": true}, appliedKeys(ingress, "labels"))
+	assert.Empty(t, appliedKeys(&networkingv1.Ingress{}, "labels"))
+}
+
+func TestUpgradedManagedFields(t *testing.T) {
+	entries := []metaV1.ManagedFieldsEntry{
+		{
+			Manager:    FieldManager,
+			Operation:  metaV1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metaV1.FieldsV1{Raw: []byte(`{"f:metadata": {"f:annotations": {"f:created": {}}}, "f:spec": {"f:rules": {}}}`)},
+		},
+		{
+			Manager:    "external-dns",
+			Operation:  metaV1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metaV1.FieldsV1{Raw: []byte(`{"f:metadata": {"f:annotations": {"f:external-dns": {}}}}`)},
+		},
+		{
+			Manager:    FieldManager,
+			Operation:  metaV1.ManagedFieldsOperationApply,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metaV1.FieldsV1{Raw: []byte(`{"f:metadata": {"f:annotations": {"f:applied": {}}}}`)},
+		},
+	}
+
+	upgraded, ok := upgradedManagedFields(entries)
+	require.True(t, ok)
+	require.Len(t, upgraded, 2)
+	assert.Equal(t, "external-dns", upgraded[0].Manager)
+	assert.Equal(t, metaV1.ManagedFieldsOperationApply, upgraded[1].Operation)
+	assert.JSONEq(t, `{
+		"f:metadata": {"f:annotations": {"f:applied": {}, "f:created": {}}},
+		"f:spec": {"f:rules": {}}
+	}`, string(upgraded[1].FieldsV1.Raw))
+
+	// only applied fields are left
+	_, ok = upgradedManagedFields(upgraded)
+	assert.False(t, ok)
+
+	// the apply operation is added when missing
+	upgraded, ok = upgradedManagedFields(entries[:2])
+	require.True(t, ok)
+	require.Len(t, upgraded, 2)
+	assert.Equal(t, FieldManager, upgraded[1].Manager)
+	assert.Equal(t, metaV1.ManagedFieldsOperationApply, upgraded[1].Operation)
+	assert.JSONEq(t, string(entries[0].FieldsV1.Raw), string(upgraded[1].FieldsV1.Raw))
+}
+
+func TestCreateResultIngressDoesNotTakeOverIngress(t *testing.T) {
+	ctx := context.Background()
+
+	// created since the name was looked up
+	other := newTestIngress("kubernetes-shared-ingress", "foo.example.org", "/")
+	reconciler := newTestReconciler([]runtime.Object{&other})
+
+	result := newTestResult("kubernetes-shared-ingress", "kubernetes-shared-ingress")
+	err := reconciler.createResultIngress(ctx, result)
+	assert.True(t, k8sErrors.IsAlreadyExists(err), err)
+
+	ingress := &networkingv1.Ingress{}
+	require.NoError(t, reconciler.Client.Get(ctx, client.ObjectKeyFromObject(&other), ingress))
+	assert.NotContains(t, ingress.Annotations, ResultAnnotation)
+}
+
+func TestHasIngressChangedAppliedKeys(t *testing.T) {
+	r := newTestReconciler(nil)
+
+	applied := &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Labels:      map[string]string{"team": "payments"},
+			Annotations: map[string]string{ResultAnnotation: "true"},
+			ManagedFields: []metaV1.ManagedFieldsEntry{
+				{
+					Manager:    FieldManager,
+					Operation:  metaV1.ManagedFieldsOperationApply,
+					FieldsType: "FieldsV1",
+					FieldsV1: &metaV1.FieldsV1{Raw: []byte(`{"f:metadata": {
+						"f:annotations": {"f:merge.ingress.kubernetes.io/result": {}},
+						"f:labels": {"f:team": {}}
+					}}`)},
+				},
+			},
+		},
+	}
+
+	existing := applied.DeepCopy()
+	existing.Labels["external"] = "true"
+	existing.Annotations["external-dns"] = "true"
+
+	assert.False(t, r.hasIngressChanged(existing, &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Labels:      map[string]string{"team": "payments"},
+			Annotations: map[string]string{ResultAnnotation: "true"},
+		},
+	}))
+
+	// a label applied before is removed
+	assert.True(t, r.hasIngressChanged(existing, &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Annotations: map[string]string{ResultAnnotation: "true"},
+		},
+	}))
+
+	assert.True(t, r.hasIngressChanged(existing, &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Labels:      map[string]string{"team": "checkout"},
+			Annotations: map[string]string{ResultAnnotation: "true"},
+		},
+	}))
+}
+
+func TestReconcileDoesNotTakeOverIngress(t *testing.T) {
+	ctx := context.Background()
+
+	reconciler := newTestReconciler([]runtime.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      "kubernetes-shared-ingress",
+			},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      "my-instance",
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+					ConfigAnnotation:       "kubernetes-shared-ingress",
+				},
+			},
+		},
+		// not a result ingress
+		&networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      "kubernetes-shared-ingress",
+			},
+		},
+	})
+
+	_, err := reconciler.Reconcile(ctx, mergeGroupRequest("my-namespace", "kubernetes-shared-ingress"))
+	require.NoError(t, err)
+
+	ingress := &networkingv1.Ingress{}
+	require.NoError(t, reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "my-namespace", Name: "kubernetes-shared-ingress"}, ingress))
+	assert.NotContains(t, ingress.Annotations, ResultAnnotation)
+
+	// the result ingress takes the next free ordinal
+	require.NoError(t, reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "my-namespace", Name: "kubernetes-shared-ingress-1"}, ingress))
+	assert.Equal(t, "true", ingress.Annotations[ResultAnnotation])
+	assert.Equal(t, "kubernetes-shared-ingress", ingress.Annotations[FromConfigAnnotation])
+}
@@ -0,0 +1,197 @@
+package ingress_merge
+
+import (
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// BucketCapacity is the number of slots of a result ingress and how source
+// ingresses use them, slots are counted for GCE without a SlotCounter, along
+// with the limits of the other resources of a result ingress.
+type BucketCapacity struct {
+	MaxSlots    int
+	SlotCounter SlotCounter
+	Limits      BucketLimits
+}
+
+func (c BucketCapacity) newBucket() *IngressBucket {
+	return &IngressBucket{
+		FreeSlots: c.MaxSlots,
+		counter:   c.SlotCounter,
+		limits:    c.Limits,
+	}
+}
+
+func (c BucketCapacity) slots(ingresses []networkingv1.Ingress) int {
+	counter := c.SlotCounter
+	if counter == nil {
+		counter = GCESlotCounter{}
+	}
+
+	slots := 0
+	for i := range ingresses {
+		slots += counter.Slots(&ingresses[i])
+	}
+
+	return slots
+}
+
+// exceeded describes what the ingresses need beyond an empty result
+// ingress, nothing when they fit into one.
+func (c BucketCapacity) exceeded(ingresses []networkingv1.Ingress) []string {
+	return c.newBucket().exceeded(ingresses)
+}
+
+func (c BucketCapacity) fits(ingresses []networkingv1.Ingress) bool {
+	return len(c.exceeded(ingresses)) == 0
+}
+
+// BucketLimits caps the resources of a result ingress that providers limit
+// besides the slots, e.g. the certificates of a load balancer. A limit of
+// zero is not enforced.
+type BucketLimits struct {
+	MaxPaths        int
+	MaxHosts        int
+	MaxCertificates int
+	MaxBackends     int
+}
+
+// withDefaults returns the limits with the unset ones taken from defaults.
+func (l BucketLimits) withDefaults(defaults BucketLimits) BucketLimits {
+	for _, limit := range []struct {
+		value    *int
+		fallback int
+	}{
+		{&l.MaxPaths, defaults.MaxPaths},
+		{&l.MaxHosts, defaults.MaxHosts},
+		{&l.MaxCertificates, defaults.MaxCertificates},
+		{&l.MaxBackends, defaults.MaxBackends},
+	} {
+		if *limit.value == 0 {
+			*limit.value = limit.fallback
+		}
+	}
+
+	return l
+}
+
+// exceeded describes the limits the usage goes beyond.
+func (l BucketLimits) exceeded(usage bucketUsage) []string {
+	exceeded := []string{}
+	for _, resource := range []struct {
+		name  string
+		used  int
+		limit int
+	}{
+		{"paths", usage.paths, l.MaxPaths},
+		{"hosts", len(usage.hosts), l.MaxHosts},
+		{"certificates", len(usage.certificates), l.MaxCertificates},
+		{"backends", len(usage.backends), l.MaxBackends},
+	} {
+		if resource.limit > 0 && resource.used > resource.limit {
+			exceeded = append(exceeded, fmt.Sprintf("%d %s of %d", resource.used, resource.name, resource.limit))
+		}
+	}
+
+	return exceeded
+}
+
+// bucketUsage counts the resources used by the ingresses of a bucket, the
+// distinct ones with the number of ingresses using them.
+type bucketUsage struct {
+	paths        int
+	hosts        map[string]int
+	certificates map[string]int
+	backends     map[string]int
+}
+
+func (u *bucketUsage) add(ingress *networkingv1.Ingress) {
+	u.update(ingress, 1)
+}
+
+func (u *bucketUsage) remove(ingress *networkingv1.Ingress) {
+	u.update(ingress, -1)
+}
+
+func (u *bucketUsage) update(ingress *networkingv1.Ingress, delta int) {
+	hosts := map[string]bool{}
+	certificates := map[string]bool{}
+	backends := map[string]bool{}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			hosts[rule.Host] = true
+		}
+
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			u.paths += delta
+			if service := path.Backend.Service; service != nil {
+				backends[fmt.Sprintf("%s:%s", service.Name, servicePort(service.Port))] = true
+			}
+		}
+	}
+
+	for _, tls := range ingress.Spec.TLS {
+		if tls.SecretName != "" {
+			certificates[tls.SecretName] = true
+		}
+	}
+
+	u.hosts = updateCounts(u.hosts, hosts, delta)
+	u.certificates = updateCounts(u.certificates, certificates, delta)
+	u.backends = updateCounts(u.backends, backends, delta)
+}
+
+// with returns the usage once the ingresses are added, leaving the usage
+// untouched.
+func (u bucketUsage) with(ingresses []networkingv1.Ingress) bucketUsage {
+	usage := bucketUsage{
+		paths:        u.paths,
+		hosts:        copyCounts(u.hosts),
+		certificates: copyCounts(u.certificates),
+		backends:     copyCounts(u.backends),
+	}
+
+	for i := range ingresses {
+		usage.add(&ingresses[i])
+	}
+
+	return usage
+}
+
+func updateCounts(counts map[string]int, keys map[string]bool, delta int) map[string]int {
+	if counts == nil {
+		counts = make(map[string]int)
+	}
+
+	for key := range keys {
+		counts[key] += delta
+		if counts[key] <= 0 {
+			delete(counts, key)
+		}
+	}
+
+	return counts
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for key, count := range counts {
+		copied[key] = count
+	}
+
+	return copied
+}
+
+func servicePort(port networkingv1.ServiceBackendPort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+
+	return fmt.Sprint(port.Number)
+}
@@ -0,0 +1,127 @@
+package ingress_merge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+var limitsStrategies = map[string]PackingStrategy{
+	FirstFitPackingName:          FirstFitPacking{},
+	BestFitDecreasingPackingName: BestFitDecreasingPacking{},
+}
+
+func TestGenerateIngressBucketsLimits(t *testing.T) {
+	// first fit places the newest ingresses first
+	created := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	origins := []networkingv1.Ingress{
+		createdAt(withTLS(newTestIngress("a", "a.example.org", numberedPaths("a", 1)...), "shared-tls"), created.Add(4*time.Hour)),
+		createdAt(withTLS(newTestIngress("b", "b.example.org", numberedPaths("b", 1)...), "shared-tls"), created.Add(3*time.Hour)),
+		createdAt(withTLS(newTestIngress("c", "c.example.org", numberedPaths("c", 1)...), "c-tls"), created.Add(2*time.Hour)),
+		createdAt(withTLS(newTestIngress("d", "d.example.org", numberedPaths("d", 1)...), "d-tls"), created.Add(time.Hour)),
+	}
+
+	tests := []struct {
+		name    string
+		limits  BucketLimits
+		buckets [][]string
+	}{
+		{
+			name:    "no limits",
+			buckets: [][]string{{"a", "b", "c", "d"}},
+		},
+		{
+			name:    "certificates shared by ingresses are counted once",
+			limits:  BucketLimits{MaxCertificates: 2},
+			buckets: [][]string{{"a", "b", "c"}, {"d"}},
+		},
+		{
+			name:    "hosts",
+			limits:  BucketLimits{MaxHosts: 3},
+			buckets: [][]string{{"a", "b", "c"}, {"d"}},
+		},
+		{
+			name:    "paths",
+			limits:  BucketLimits{MaxPaths: 2},
+			buckets: [][]string{{"a", "b"}, {"c", "d"}},
+		},
+	}
+
+	for strategyName, strategy := range limitsStrategies {
+		for _, tt := range tests {
+			t.Run(strategyName+"/"+tt.name, func(t *testing.T) {
+				capacity := BucketCapacity{MaxSlots: 10, Limits: tt.limits}
+				buckets := GenerateIngressBucketsWithStrategy(origins, nil, capacity, strategy)
+
+				names := [][]string{}
+				for _, bucket := range buckets {
+					bucketNames := []string{}
+					for _, ingress := range bucket.Ingresses {
+						bucketNames = append(bucketNames, ingress.Name)
+					}
+					names = append(names, bucketNames)
+				}
+				assert.Equal(t, tt.buckets, names)
+			})
+		}
+	}
+}
+
+func TestGenerateIngressBucketsLimitsWithDestinations(t *testing.T) {
+	origins := []networkingv1.Ingress{
+		newTestIngress("a", "a.example.org", "/"),
+		newTestIngress("b", "b.example.org", "/"),
+		newTestIngress("c", "c.example.org", "/"),
+	}
+	capacity := BucketCapacity{MaxSlots: 10, Limits: BucketLimits{MaxHosts: 1}}
+
+	// none of the result ingresses has room for another host
+	for strategyName, strategy := range limitsStrategies {
+		t.Run(strategyName, func(t *testing.T) {
+			destinations := []networkingv1.Ingress{
+				*newTestResult("r1", "shared", "a"),
+				*newTestResult("r2", "shared", "b"),
+			}
+
+			buckets := GenerateIngressBucketsWithStrategy(origins, destinations, capacity, strategy)
+			assert.Equal(t, map[string][]string{
+				"r1": {"a"},
+				"r2": {"b"},
+				"":   {"c"},
+			}, bucketNames(buckets))
+		})
+	}
+}
+
+func TestBucketUsage(t *testing.T) {
+	bucket := BucketCapacity{MaxSlots: 10}.newBucket()
+	a := withTLS(newTestIngress("a", "a.example.org", numberedPaths("a", 1)...), "shared-tls")
+	b := withTLS(newTestIngress("b", "b.example.org", numberedPaths("b", 1)...), "shared-tls")
+
+	bucket.add(a)
+	bucket.add(b)
+	assert.Equal(t, 2, bucket.usage.paths)
+	assert.Equal(t, map[string]int{"shared-tls": 2}, bucket.usage.certificates)
+
+	bucket.remove(a)
+	assert.Equal(t, 1, bucket.usage.paths)
+	assert.Equal(t, map[string]int{"shared-tls": 1}, bucket.usage.certificates)
+	assert.Equal(t, map[string]int{"b.example.org": 1}, bucket.usage.hosts)
+}
+
+func TestUnschedulableHostGroupsLimits(t *testing.T) {
+	origins := []networkingv1.Ingress{
+		newTestIngress("a", "x.example.org", numberedPaths("a", 2)...),
+		newTestIngress("b", "x.example.org", numberedPaths("b", 1)...),
+		newTestIngress("c", "y.example.org", numberedPaths("c", 2)...),
+	}
+	capacity := BucketCapacity{MaxSlots: 10, Limits: BucketLimits{MaxPaths: 2}}
+
+	unschedulable := UnschedulableHostGroups(origins, capacity)
+	require.Len(t, unschedulable, 1)
+	assert.Len(t, unschedulable[0], 2)
+	assert.Equal(t, []string{"3 paths of 2"}, capacity.exceeded(unschedulable[0]))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	ingress_merge "github.com/tsuru/ingress-merge"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// addMergeFlags adds the flags changing how source ingresses are merged,
+// shared by the controller and the commands computing merges outside of it.
+func addMergeFlags(cmd *cobra.Command) {
+	cmd.Flags().String(
+		"ingress-class",
+		"merge",
+		"Process ingress resources with this `kubernetes.io/ingress.class` annotation.",
+	)
+
+	cmd.Flags().Int(
+		"ingress-max-slots",
+		45,
+		"the ingress provider may have a limit of number of ingress rules and paths, i.e: GCE ingress controller",
+	)
+
+	cmd.Flags().String(
+		"slot-counter",
+		ingress_merge.GCESlotCounterName,
+		"How source ingresses use the slots of a result ingress: gce, aws-alb or unlimited.",
+	)
+
+	cmd.Flags().Bool(
+		"enable-bucket-compaction",
+		false,
+		"Move source ingresses out of the least used result ingresses when they fit into fewer result ingresses.",
+	)
+}
+
+// mergeOptions reads the flags added by addMergeFlags.
+func mergeOptions(cmd *cobra.Command) (ingress_merge.RenderOptions, error) {
+	ingressClass, err := cmd.Flags().GetString("ingress-class")
+	if err != nil {
+		return ingress_merge.RenderOptions{}, err
+	}
+
+	ingressMaxSlots, err := cmd.Flags().GetInt("ingress-max-slots")
+	if err != nil {
+		return ingress_merge.RenderOptions{}, err
+	}
+	if ingressMaxSlots < 1 {
+		return ingress_merge.RenderOptions{}, fmt.Errorf("invalid --ingress-max-slots %d: must be at least 1", ingressMaxSlots)
+	}
+
+	slotCounterName, err := cmd.Flags().GetString("slot-counter")
+	if err != nil {
+		return ingress_merge.RenderOptions{}, err
+	}
+
+	slotCounter, err := ingress_merge.ParseSlotCounter(slotCounterName)
+	if err != nil {
+		return ingress_merge.RenderOptions{}, fmt.Errorf("invalid --slot-counter %q: %w", slotCounterName, err)
+	}
+
+	enableBucketCompaction, err := cmd.Flags().GetBool("enable-bucket-compaction")
+	if err != nil {
+		return ingress_merge.RenderOptions{}, err
+	}
+
+	return ingress_merge.RenderOptions{
+		IngressClass:           ingressClass,
+		IngressMaxSlots:        ingressMaxSlots,
+		SlotCounter:            slotCounter,
+		EnableBucketCompaction: enableBucketCompaction,
+	}, nil
+}
+
+// sourceOptions select the source ingresses and configs of the commands
+// reading them from the cluster.
+type sourceOptions struct {
+	IngressSelector      labels.Selector
+	ConfigMapSelector    labels.Selector
+	IngressWatchIgnore   []string
+	ConfigMapWatchIgnore []string
+	EnableIngressMerge   bool
+}
+
+// addSourceFlags adds the flags selecting source ingresses and configs,
+// shared by the controller and the commands reading them from the cluster.
+func addSourceFlags(cmd *cobra.Command) {
+	cmd.Flags().String(
+		"ingress-selector",
+		"",
+		"Process ingress resources with labels matching this selector string.",
+	)
+
+	cmd.Flags().String(
+		"configmap-selector",
+		"",
+		"Process configmap resources with labels matching this selector string.",
+	)
+
+	cmd.Flags().StringArray(
+		"ingress-watch-ignore",
+		[]string{},
+		"Ignore ingress resources with matching annotations (can be specified multiple times).",
+	)
+
+	cmd.Flags().StringArray(
+		"configmap-watch-ignore",
+		[]string{},
+		"Ignore configmap resources with matching annotations (can be specified multiple times).",
+	)
+
+	cmd.Flags().Bool(
+		"enable-ingress-merge",
+		false,
+		"Use IngressMerge resources as merge configuration, the CRD must be installed.",
+	)
+}
+
+// readSourceOptions reads the flags added by addSourceFlags.
+func readSourceOptions(cmd *cobra.Command) (sourceOptions, error) {
+	ingressSelectorString, err := cmd.Flags().GetString("ingress-selector")
+	if err != nil {
+		return sourceOptions{}, err
+	}
+
+	ingressSelector, err := labels.Parse(ingressSelectorString)
+	if err != nil {
+		return sourceOptions{}, fmt.Errorf("invalid --ingress-selector %q: %w", ingressSelectorString, err)
+	}
+
+	configMapSelectorString, err := cmd.Flags().GetString("configmap-selector")
+	if err != nil {
+		return sourceOptions{}, err
+	}
+
+	configMapSelector, err := labels.Parse(configMapSelectorString)
+	if err != nil {
+		return sourceOptions{}, fmt.Errorf("invalid --configmap-selector %q: %w", configMapSelectorString, err)
+	}
+
+	ingressWatchIgnore, err := cmd.Flags().GetStringArray("ingress-watch-ignore")
+	if err != nil {
+		return sourceOptions{}, err
+	}
+
+	configMapWatchIgnore, err := cmd.Flags().GetStringArray("configmap-watch-ignore")
+	if err != nil {
+		return sourceOptions{}, err
+	}
+
+	enableIngressMerge, err := cmd.Flags().GetBool("enable-ingress-merge")
+	if err != nil {
+		return sourceOptions{}, err
+	}
+
+	return sourceOptions{
+		IngressSelector:      ingressSelector,
+		ConfigMapSelector:    configMapSelector,
+		IngressWatchIgnore:   ingressWatchIgnore,
+		ConfigMapWatchIgnore: configMapWatchIgnore,
+		EnableIngressMerge:   enableIngressMerge,
+	}, nil
+}
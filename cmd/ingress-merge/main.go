@@ -1,17 +1,24 @@
 package main
 
 import (
+	"context"
 	goflag "flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	ingress_merge "github.com/tsuru/ingress-merge"
+	mergev1alpha1 "github.com/tsuru/ingress-merge/api/v1alpha1"
 	"k8s.io/api/node/v1alpha1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
@@ -23,6 +30,7 @@ var (
 func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = v1alpha1.AddToScheme(scheme)
+	_ = mergev1alpha1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -34,60 +42,189 @@ var rootCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		ingressClass, err := cmd.Flags().GetString("ingress-class")
+		opts, err := mergeOptions(cmd)
 		if err != nil {
 			return err
 		}
-		ingressSelector, err := cmd.Flags().GetString("ingress-selector")
+
+		sources, err := readSourceOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		resultIngressGracePeriod, err := cmd.Flags().GetDuration("result-ingress-grace-period")
+		if err != nil {
+			return err
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return err
+		}
+
+		enableWebhook, err := cmd.Flags().GetBool("enable-webhook")
+		if err != nil {
+			return err
+		}
+
+		webhookCertDir, err := cmd.Flags().GetString("webhook-cert-dir")
+		if err != nil {
+			return err
+		}
+
+		healthProbeAddr, err := cmd.Flags().GetString("health-probe-addr")
+		if err != nil {
+			return err
+		}
+
+		leaderElect, err := cmd.Flags().GetBool("leader-elect")
+		if err != nil {
+			return err
+		}
+
+		leaderElectionID, err := cmd.Flags().GetString("leader-election-id")
+		if err != nil {
+			return err
+		}
+
+		leaderElectionNamespace, err := cmd.Flags().GetString("leader-election-namespace")
+		if err != nil {
+			return err
+		}
+
+		leaseDuration, err := cmd.Flags().GetDuration("leader-election-lease-duration")
+		if err != nil {
+			return err
+		}
+
+		renewDeadline, err := cmd.Flags().GetDuration("leader-election-renew-deadline")
+		if err != nil {
+			return err
+		}
+
+		retryPeriod, err := cmd.Flags().GetDuration("leader-election-retry-period")
 		if err != nil {
 			return err
 		}
 
-		configMapSelector, err := cmd.Flags().GetString("configmap-selector")
+		maxConcurrentReconciles, err := cmd.Flags().GetInt("max-concurrent-reconciles")
 		if err != nil {
 			return err
 		}
 
-		ingressWatchIgnore, err := cmd.Flags().GetStringArray("ingress-watch-ignore")
+		reconcileBaseDelay, err := cmd.Flags().GetDuration("reconcile-base-delay")
 		if err != nil {
 			return err
 		}
 
-		configMapWatchIgnore, err := cmd.Flags().GetStringArray("configmap-watch-ignore")
+		reconcileMaxDelay, err := cmd.Flags().GetDuration("reconcile-max-delay")
 		if err != nil {
 			return err
 		}
 
-		ingressMaxSlots, err := cmd.Flags().GetInt("ingress-max-slots")
+		reconcileQPS, err := cmd.Flags().GetFloat64("reconcile-qps")
 		if err != nil {
 			return err
 		}
 
-		mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-			Scheme:             scheme,
-			MetricsBindAddress: metricsAddr,
-			Port:               9443,
+		reconcileBurst, err := cmd.Flags().GetInt("reconcile-burst")
+		if err != nil {
+			return err
+		}
+
+		settleWindow, err := cmd.Flags().GetDuration("settle-window")
+		if err != nil {
+			return err
+		}
+
+		maxSettleDelay, err := cmd.Flags().GetDuration("max-settle-delay")
+		if err != nil {
+			return err
+		}
+
+		clientQPS, err := cmd.Flags().GetFloat32("client-qps")
+		if err != nil {
+			return err
+		}
+
+		clientBurst, err := cmd.Flags().GetInt("client-burst")
+		if err != nil {
+			return err
+		}
+
+		restConfig := ctrl.GetConfigOrDie()
+		restConfig.QPS = clientQPS
+		restConfig.Burst = clientBurst
+
+		mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+			Scheme:                  scheme,
+			MetricsBindAddress:      metricsAddr,
+			HealthProbeBindAddress:  healthProbeAddr,
+			Port:                    9443,
+			CertDir:                 webhookCertDir,
+			LeaderElection:          leaderElect,
+			LeaderElectionID:        leaderElectionID,
+			LeaderElectionNamespace: leaderElectionNamespace,
+			LeaseDuration:           &leaseDuration,
+			RenewDeadline:           &renewDeadline,
+			RetryPeriod:             &retryPeriod,
 		})
 
 		if err != nil {
 			return err
 		}
 
+		if err = mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+			setupLog.Error(err, "unable to set up health check")
+			return err
+		}
+
+		if err = mgr.AddReadyzCheck("cache-sync", cacheSyncCheck(mgr.GetCache())); err != nil {
+			setupLog.Error(err, "unable to set up ready check")
+			return err
+		}
+
 		if err = (&ingress_merge.IngressReconciler{
-			Client: mgr.GetClient(),
-			Log:    ctrl.Log.WithName("controllers").WithName("IngressReconciler"),
-
-			IngressClass:         ingressClass,
-			IngressSelector:      ingressSelector,
-			ConfigMapSelector:    configMapSelector,
-			IngressWatchIgnore:   ingressWatchIgnore,
-			ConfigMapWatchIgnore: configMapWatchIgnore,
-			IngressMaxSlots:      ingressMaxSlots,
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("controllers").WithName("IngressReconciler"),
+			Recorder: mgr.GetEventRecorderFor("ingress-merge"),
+
+			IngressClass:         opts.IngressClass,
+			IngressSelector:      sources.IngressSelector,
+			ConfigMapSelector:    sources.ConfigMapSelector,
+			IngressWatchIgnore:   sources.IngressWatchIgnore,
+			ConfigMapWatchIgnore: sources.ConfigMapWatchIgnore,
+			IngressMaxSlots:      opts.IngressMaxSlots,
+			SlotCounter:          opts.SlotCounter,
+
+			ResultIngressGracePeriod: resultIngressGracePeriod,
+			EnableBucketCompaction:   opts.EnableBucketCompaction,
+			EnableIngressMerge:       sources.EnableIngressMerge,
+			DryRun:                   dryRun,
+
+			MaxConcurrentReconciles: maxConcurrentReconciles,
+			RateLimiter:             ingress_merge.NewRateLimiter(reconcileBaseDelay, reconcileMaxDelay, reconcileQPS, reconcileBurst),
+			SettleWindow:            settleWindow,
+			MaxSettleDelay:          maxSettleDelay,
 		}).SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "RpaasInstance")
 			return err
 		}
 
+		if enableWebhook {
+			// the controller registers the ingress indexes on the cache of the manager
+			if err = (&ingress_merge.Validator{
+				Client:             mgr.GetClient(),
+				IngressClass:       opts.IngressClass,
+				IngressSelector:    sources.IngressSelector,
+				IngressWatchIgnore: sources.IngressWatchIgnore,
+				Indexed:            true,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create webhook", "webhook", "Validator")
+				return err
+			}
+		}
+
 		setupLog.Info("starting manager")
 		if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 			setupLog.Error(err, "problem running manager")
@@ -98,6 +235,21 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// cacheSyncCheck reports ready only once the informers of the cache have
+// synced, so a new replica does not receive traffic before it can serve it.
+func cacheSyncCheck(c cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), time.Second)
+		defer cancel()
+
+		if !c.WaitForCacheSync(ctx) {
+			return fmt.Errorf("informer cache is not synced yet")
+		}
+
+		return nil
+	}
+}
+
 func main() {
 	logger := zap.New(zap.UseDevMode(true))
 	ctrl.SetLogger(logger)
@@ -112,41 +264,131 @@ func main() {
 		"The address the metric endpoint binds to.",
 	)
 	rootCmd.Flags().String(
-		"ingress-class",
-		"merge",
-		"Process ingress resources with this `kubernetes.io/ingress.class` annotation.",
+		"health-probe-addr",
+		":8081",
+		"The address the health (/healthz) and readiness (/readyz) probe endpoints bind to.",
+	)
+
+	rootCmd.Flags().Bool(
+		"leader-elect",
+		false,
+		"Enable leader election, so only one of the running replicas merges ingresses.",
 	)
 
 	rootCmd.Flags().String(
-		"ingress-selector",
-		"",
-		"Process ingress resources with labels matching this selector string.",
+		"leader-election-id",
+		"ingress-merge-leader",
+		"Name of the resource used as leader election lock.",
 	)
 
 	rootCmd.Flags().String(
-		"configmap-selector",
+		"leader-election-namespace",
 		"",
-		"Process configmap resources with labels matching this selector string.",
+		"Namespace of the leader election lock, defaults to the namespace the controller runs in.",
 	)
 
-	rootCmd.Flags().StringArray(
-		"ingress-watch-ignore",
-		[]string{},
-		"Ignore ingress resources with matching annotations (can be specified multiple times).",
+	rootCmd.Flags().Duration(
+		"leader-election-lease-duration",
+		15*time.Second,
+		"How long non-leader replicas wait before trying to acquire a lease that was not renewed.",
 	)
 
-	rootCmd.Flags().StringArray(
-		"configmap-watch-ignore",
-		[]string{},
-		"Ignore configmap resources with matching annotations (can be specified multiple times).",
+	rootCmd.Flags().Duration(
+		"leader-election-renew-deadline",
+		10*time.Second,
+		"How long the leader retries renewing its lease before giving up leadership.",
+	)
+
+	rootCmd.Flags().Duration(
+		"leader-election-retry-period",
+		2*time.Second,
+		"How long replicas wait between leader election attempts.",
 	)
 
 	rootCmd.Flags().Int(
-		"ingress-max-slots",
-		45,
-		"the ingress provider may have a limit of number of ingress rules and paths, i.e: GCE ingress controller",
+		"max-concurrent-reconciles",
+		1,
+		"How many merge groups are reconciled at the same time.",
 	)
 
+	rootCmd.Flags().Duration(
+		"reconcile-base-delay",
+		5*time.Millisecond,
+		"Delay before retrying a failed merge group, doubled on each failure.",
+	)
+
+	rootCmd.Flags().Duration(
+		"reconcile-max-delay",
+		1000*time.Second,
+		"Maximum delay before retrying a failed merge group.",
+	)
+
+	rootCmd.Flags().Float64(
+		"reconcile-qps",
+		10,
+		"How many merge groups per second can be requeued overall.",
+	)
+
+	rootCmd.Flags().Int(
+		"reconcile-burst",
+		100,
+		"How many merge groups can be requeued at once above --reconcile-qps.",
+	)
+
+	rootCmd.Flags().Duration(
+		"settle-window",
+		0,
+		"Wait for the source ingresses and config of a merge group to stop changing for this long before merging them.",
+	)
+
+	rootCmd.Flags().Duration(
+		"max-settle-delay",
+		30*time.Second,
+		"How long a merge group that keeps changing waits at most before being merged, no limit when zero.",
+	)
+
+	rootCmd.Flags().Float32(
+		"client-qps",
+		20,
+		"How many requests per second the controller sends to the API server.",
+	)
+
+	rootCmd.Flags().Int(
+		"client-burst",
+		30,
+		"How many requests the controller can send at once to the API server above --client-qps.",
+	)
+
+	rootCmd.Flags().Duration(
+		"result-ingress-grace-period",
+		0,
+		"How long a result ingress without source ingresses is kept before being deleted.",
+	)
+
+	rootCmd.Flags().Bool(
+		"dry-run",
+		false,
+		"Compute every merge but send the writes with server-side dry-run, so nothing is changed in the cluster.",
+	)
+
+	rootCmd.Flags().Bool(
+		"enable-webhook",
+		false,
+		"Serve the validating admission webhook for merge ingresses and configmaps on port 9443.",
+	)
+
+	rootCmd.Flags().String(
+		"webhook-cert-dir",
+		"",
+		"Directory containing tls.crt and tls.key of the webhook server, defaults to the controller-runtime one.",
+	)
+
+	addMergeFlags(rootCmd)
+	addSourceFlags(rootCmd)
+
+	rootCmd.AddCommand(newRenderCmd())
+	rootCmd.AddCommand(newPlanCmd())
+
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
 	}
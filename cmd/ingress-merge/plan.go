@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+	ingress_merge "github.com/tsuru/ingress-merge"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var resultActionSymbols = map[ingress_merge.ResultAction]string{
+	ingress_merge.CreateResultAction: "+",
+	ingress_merge.UpdateResultAction: "~",
+	ingress_merge.EmptyResultAction:  "-",
+}
+
+func newPlanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Print what a reconcile would change on the result ingresses of the cluster, without changing anything",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace, err := cmd.Flags().GetString("namespace")
+			if err != nil {
+				return err
+			}
+
+			sources, err := readSourceOptions(cmd)
+			if err != nil {
+				return err
+			}
+
+			opts, err := mergeOptions(cmd)
+			if err != nil {
+				return err
+			}
+
+			config, err := ctrl.GetConfig()
+			if err != nil {
+				return err
+			}
+
+			c, err := client.New(config, client.Options{Scheme: scheme})
+			if err != nil {
+				return err
+			}
+
+			reconciler := &ingress_merge.IngressReconciler{
+				Client:   c,
+				Log:      logr.Discard(),
+				Recorder: &warningRecorder{out: cmd.ErrOrStderr()},
+
+				IngressClass:         opts.IngressClass,
+				IngressSelector:      sources.IngressSelector,
+				ConfigMapSelector:    sources.ConfigMapSelector,
+				IngressWatchIgnore:   sources.IngressWatchIgnore,
+				ConfigMapWatchIgnore: sources.ConfigMapWatchIgnore,
+				IngressMaxSlots:      opts.IngressMaxSlots,
+				SlotCounter:          opts.SlotCounter,
+
+				EnableBucketCompaction: opts.EnableBucketCompaction,
+				EnableIngressMerge:     sources.EnableIngressMerge,
+			}
+
+			ctx := context.Background()
+
+			namespaces := []string{namespace}
+			if namespace == "" {
+				namespaces, err = ingressNamespaces(ctx, c)
+				if err != nil {
+					return err
+				}
+			}
+
+			counts := make(map[ingress_merge.ResultAction]int)
+			for _, ns := range namespaces {
+				changes, err := reconciler.Plan(ctx, ns)
+				if err != nil {
+					return err
+				}
+
+				for _, change := range changes {
+					counts[change.Action]++
+					if change.Action == ingress_merge.UnchangedResultAction {
+						continue
+					}
+
+					fmt.Fprintf(cmd.OutOrStdout(), "%s %s %s/%s\n", resultActionSymbols[change.Action], change.Action, ns, change.Name())
+					for _, line := range strings.Split(strings.TrimRight(change.Diff(), "\n"), "\n") {
+						fmt.Fprintf(cmd.OutOrStdout(), "    %s\n", line)
+					}
+				}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%d to create, %d to update, %d becoming empty, %d unchanged\n",
+				counts[ingress_merge.CreateResultAction],
+				counts[ingress_merge.UpdateResultAction],
+				counts[ingress_merge.EmptyResultAction],
+				counts[ingress_merge.UnchangedResultAction])
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP(
+		"namespace",
+		"n",
+		"",
+		"Namespace to plan, defaults to every namespace with ingresses.",
+	)
+
+	addSourceFlags(cmd)
+	addMergeFlags(cmd)
+
+	return cmd
+}
+
+// ingressNamespaces returns the namespaces with ingresses.
+func ingressNamespaces(ctx context.Context, c client.Client) ([]string, error) {
+	ingresses := &networkingv1.IngressList{}
+	if err := c.List(ctx, ingresses); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	namespaces := []string{}
+	for _, ingress := range ingresses.Items {
+		if !seen[ingress.Namespace] {
+			seen[ingress.Namespace] = true
+			namespaces = append(namespaces, ingress.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
+
+// warningRecorder prints the warning events of a plan instead of recording
+// them.
+type warningRecorder struct {
+	out io.Writer
+}
+
+func (r *warningRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if eventtype != corev1.EventTypeWarning {
+		return
+	}
+
+	name := ""
+	if obj, ok := object.(metaV1.Object); ok {
+		name = obj.GetNamespace() + "/" + obj.GetName() + " "
+	}
+
+	fmt.Fprintf(r.out, "warning: %s%s: %s\n", name, reason, message)
+}
+
+func (r *warningRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *warningRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Eventf(object, eventtype, reason, messageFmt, args...)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	ingress_merge "github.com/tsuru/ingress-merge"
+	mergev1alpha1 "github.com/tsuru/ingress-merge/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// renderInput holds the objects read from the manifests, other kinds are
+// ignored.
+type renderInput struct {
+	ingresses     []networkingv1.Ingress
+	configMaps    []corev1.ConfigMap
+	ingressMerges []mergev1alpha1.IngressMerge
+	namespaces    []corev1.Namespace
+}
+
+func newRenderCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "render -f FILENAME...",
+		Short: "Print the result ingresses merged from manifests, without a cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filenames, err := cmd.Flags().GetStringArray("filename")
+			if err != nil {
+				return err
+			}
+
+			opts, err := mergeOptions(cmd)
+			if err != nil {
+				return err
+			}
+
+			if len(filenames) == 0 {
+				return fmt.Errorf("at least one --filename is required")
+			}
+
+			input := &renderInput{}
+			for _, filename := range filenames {
+				if err := input.readPath(filename, cmd.InOrStdin()); err != nil {
+					return err
+				}
+			}
+
+			resultIngresses, warnings := ingress_merge.Render(input.ingresses, input.configMaps, input.ingressMerges, input.namespaces, opts)
+
+			for _, warning := range warnings {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: %v\n", warning)
+			}
+
+			for _, resultIngress := range resultIngresses {
+				data, err := yaml.Marshal(resultIngress)
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "---\n%s", data)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayP(
+		"filename",
+		"f",
+		nil,
+		"File or directory of Ingress, ConfigMap, IngressMerge and Namespace manifests, - reads from stdin (can be specified multiple times).",
+	)
+
+	addMergeFlags(cmd)
+
+	return cmd
+}
+
+// readPath reads the manifests of a file, of the YAML and JSON files of a
+// directory, or of stdin for -.
+func (in *renderInput) readPath(path string, stdin io.Reader) error {
+	if path == "-" {
+		return in.read(stdin)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return in.readFile(path)
+	}
+
+	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+			return in.readFile(path)
+		}
+
+		return nil
+	})
+}
+
+func (in *renderInput) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := in.read(f); err != nil {
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// read decodes the documents of a YAML stream, JSON documents being YAML.
+func (in *renderInput) read(r io.Reader) error {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		var typeMeta metaV1.TypeMeta
+		if err := yaml.Unmarshal(doc, &typeMeta); err != nil {
+			return err
+		}
+
+		switch typeMeta.GroupVersionKind() {
+		case networkingv1.SchemeGroupVersion.WithKind("Ingress"):
+			var ingress networkingv1.Ingress
+			if err := yaml.Unmarshal(doc, &ingress); err != nil {
+				return err
+			}
+			in.ingresses = append(in.ingresses, ingress)
+		case corev1.SchemeGroupVersion.WithKind("ConfigMap"):
+			var configMap corev1.ConfigMap
+			if err := yaml.Unmarshal(doc, &configMap); err != nil {
+				return err
+			}
+			in.configMaps = append(in.configMaps, configMap)
+		case mergev1alpha1.GroupVersion.WithKind("IngressMerge"):
+			var ingressMerge mergev1alpha1.IngressMerge
+			if err := yaml.Unmarshal(doc, &ingressMerge); err != nil {
+				return err
+			}
+			in.ingressMerges = append(in.ingressMerges, ingressMerge)
+		case corev1.SchemeGroupVersion.WithKind("Namespace"):
+			var namespace corev1.Namespace
+			if err := yaml.Unmarshal(doc, &namespace); err != nil {
+				return err
+			}
+			in.namespaces = append(in.namespaces, namespace)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package ingress_merge
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ghodss/yaml"
+	mergev1alpha1 "github.com/tsuru/ingress-merge/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// packingStrategyNames maps the packing strategies of an IngressMerge to the
+// ones of a ConfigMap.
+var packingStrategyNames = map[mergev1alpha1.PackingStrategy]string{
+	"":                                     "",
+	mergev1alpha1.FirstFitPacking:          FirstFitPackingName,
+	mergev1alpha1.BestFitDecreasingPacking: BestFitDecreasingPackingName,
+	mergev1alpha1.LabelAffinityPacking:     LabelAffinityPackingName,
+}
+
+// slotCounterNames maps the slot counters of an IngressMerge to the ones of a
+// ConfigMap.
+var slotCounterNames = map[mergev1alpha1.SlotCounter]string{
+	mergev1alpha1.GCESlotCounter:       GCESlotCounterName,
+	mergev1alpha1.ALBSlotCounter:       ALBSlotCounterName,
+	mergev1alpha1.UnlimitedSlotCounter: UnlimitedSlotCounterName,
+}
+
+// MergeConfig is the configuration of a group of source ingresses merged
+// together, read either from a ConfigMap or from an IngressMerge.
+type MergeConfig struct {
+	// Object is the ConfigMap or IngressMerge the configuration comes from.
+	Object client.Object
+	Kind   string
+
+	ResultName           string
+	Labels               map[string]string
+	Annotations          map[string]string
+	DefaultBackend       *networkingv1.IngressBackend
+	IngressClassName     string
+	UseWildcardTLS       bool
+	UseWildcardTLSIgnore labels.Selector
+	MaxSlots             int
+	IngressSelector      labels.Selector
+	PackingStrategy      PackingStrategy
+	// SlotCounter overrides the slot counter of the controller when set.
+	SlotCounter SlotCounter
+	Limits      BucketLimits
+
+	// Errors holds the values that could not be parsed, which are left
+	// empty instead of failing the whole merge.
+	Errors []error
+}
+
+func (c *MergeConfig) Name() string {
+	return c.Object.GetName()
+}
+
+func (c *MergeConfig) Namespace() string {
+	return c.Object.GetNamespace()
+}
+
+// ownerReference references the ConfigMap or IngressMerge the configuration
+// comes from.
+func (c *MergeConfig) ownerReference() metaV1.OwnerReference {
+	apiVersion := corev1.SchemeGroupVersion.String()
+	if c.Kind == "IngressMerge" {
+		apiVersion = mergev1alpha1.GroupVersion.String()
+	}
+
+	return metaV1.OwnerReference{
+		APIVersion: apiVersion,
+		Kind:       c.Kind,
+		Name:       c.Name(),
+		UID:        c.Object.GetUID(),
+	}
+}
+
+// LoopsInto tells whether the result ingress would be of the given ingress
+// class, which would make the controller merge its own results.
+func (c *MergeConfig) LoopsInto(ingressClass string) bool {
+	return c.Annotations[IngressClassAnnotation] == ingressClass || c.IngressClassName == ingressClass
+}
+
+// Capacity returns the capacity of the result ingresses, the slots and slot
+// counter of the config override the given ones of the controller, and its
+// limits override the ones of the slot counter. The AWS ALB slot counter
+// counts the listeners of the annotations of the config.
+func (c *MergeConfig) Capacity(maxSlots int, slotCounter SlotCounter) BucketCapacity {
+	capacity := BucketCapacity{
+		MaxSlots:    maxSlots,
+		SlotCounter: slotCounter,
+		Limits:      c.Limits,
+	}
+
+	if c.MaxSlots > 0 {
+		capacity.MaxSlots = c.MaxSlots
+	}
+
+	if c.SlotCounter != nil {
+		capacity.SlotCounter = c.SlotCounter
+	}
+
+	// the listeners of the load balancer are set by the annotations of the
+	// result ingress
+	if counter, ok := capacity.SlotCounter.(ALBSlotCounter); ok && counter.Listeners == 0 {
+		counter.Listeners = albListeners(c.Annotations)
+		capacity.SlotCounter = counter
+	}
+
+	if counter, ok := capacity.SlotCounter.(LimitedSlotCounter); ok {
+		capacity.Limits = capacity.Limits.withDefaults(counter.Limits())
+	}
+
+	return capacity
+}
+
+// ConfigFromConfigMap reads the merge configuration from the keys of a
+// ConfigMap.
+func ConfigFromConfigMap(configMap *corev1.ConfigMap) *MergeConfig {
+	config := &MergeConfig{
+		Object:               configMap,
+		Kind:                 "ConfigMap",
+		ResultName:           configMap.Data[NameConfigKey],
+		IngressClassName:     configMap.Data[IngressClassNameConfigKey],
+		UseWildcardTLS:       configMap.Data[UseWildcardTLSKey] == "true",
+		UseWildcardTLSIgnore: labels.Nothing(),
+		IngressSelector:      labels.Everything(),
+		PackingStrategy:      FirstFitPacking{},
+	}
+
+	if config.ResultName == "" {
+		config.ResultName = configMap.Name
+	}
+
+	if packingStrategy, err := ParsePackingStrategy(configMap.Data[PackingStrategyConfigKey], configMap.Data[PackingLabelConfigKey]); err != nil {
+		config.Errors = append(config.Errors, fmt.Errorf("invalid %s: %w", PackingStrategyConfigKey, err))
+	} else {
+		config.PackingStrategy = packingStrategy
+	}
+
+	if slotCounter := configMap.Data[SlotCounterConfigKey]; slotCounter != "" {
+		counter, err := ParseSlotCounter(slotCounter)
+		if err != nil {
+			config.Errors = append(config.Errors, fmt.Errorf("invalid %s: %w", SlotCounterConfigKey, err))
+		} else {
+			config.SlotCounter = counter
+		}
+	}
+
+	for _, limit := range []struct {
+		key   string
+		value *int
+	}{
+		{MaxSlotsConfigKey, &config.MaxSlots},
+		{MaxPathsConfigKey, &config.Limits.MaxPaths},
+		{MaxHostsConfigKey, &config.Limits.MaxHosts},
+		{MaxCertificatesConfigKey, &config.Limits.MaxCertificates},
+		{MaxBackendsConfigKey, &config.Limits.MaxBackends},
+	} {
+		data, exists := configMap.Data[limit.key]
+		if !exists {
+			continue
+		}
+
+		value, err := strconv.Atoi(data)
+		if err != nil || value < 1 {
+			config.Errors = append(config.Errors, fmt.Errorf("invalid %s %q: must be a positive integer", limit.key, data))
+			continue
+		}
+
+		*limit.value = value
+	}
+
+	if dataLabels, exists := configMap.Data[LabelsConfigKey]; exists {
+		if err := yaml.Unmarshal([]byte(dataLabels), &config.Labels); err != nil {
+			config.Labels = nil
+			config.Errors = append(config.Errors, fmt.Errorf("could not unmarshal %s: %w", LabelsConfigKey, err))
+		}
+	}
+
+	if dataAnnotations, exists := configMap.Data[AnnotationsConfigKey]; exists {
+		if err := yaml.Unmarshal([]byte(dataAnnotations), &config.Annotations); err != nil {
+			config.Annotations = nil
+			config.Errors = append(config.Errors, fmt.Errorf("could not unmarshal %s: %w", AnnotationsConfigKey, err))
+		}
+	}
+
+	if dataBackend, exists := configMap.Data[BackendConfigKey]; exists {
+		if err := yaml.Unmarshal([]byte(dataBackend), &config.DefaultBackend); err != nil {
+			config.DefaultBackend = nil
+			config.Errors = append(config.Errors, fmt.Errorf("could not unmarshal %s: %w", BackendConfigKey, err))
+		}
+	}
+
+	if useWildcardTLSIgnore := configMap.Data[UseWildcardTLSIgnoreKey]; useWildcardTLSIgnore != "" {
+		selector, err := parseLabels(useWildcardTLSIgnore)
+		if err != nil {
+			config.Errors = append(config.Errors, fmt.Errorf("could not parse %s: %w", UseWildcardTLSIgnoreKey, err))
+		} else {
+			config.UseWildcardTLSIgnore = selector
+		}
+	}
+
+	return config
+}
+
+// ConfigFromIngressMerge reads the merge configuration from the spec of an
+// IngressMerge.
+func ConfigFromIngressMerge(ingressMerge *mergev1alpha1.IngressMerge) *MergeConfig {
+	spec := ingressMerge.Spec
+	config := &MergeConfig{
+		Object:               ingressMerge,
+		Kind:                 "IngressMerge",
+		ResultName:           spec.Name,
+		Labels:               spec.Labels,
+		Annotations:          spec.Annotations,
+		DefaultBackend:       spec.DefaultBackend,
+		UseWildcardTLS:       spec.TLS.Mode == mergev1alpha1.WildcardTLSMode,
+		UseWildcardTLSIgnore: labels.Nothing(),
+		IngressSelector:      labels.Everything(),
+		PackingStrategy:      FirstFitPacking{},
+	}
+
+	if config.ResultName == "" {
+		config.ResultName = ingressMerge.Name
+	}
+
+	if packingStrategy, err := ParsePackingStrategy(packingStrategyNames[spec.Packing.Strategy], spec.Packing.AffinityLabel); err != nil {
+		config.Errors = append(config.Errors, fmt.Errorf("invalid packing: %w", err))
+	} else {
+		config.PackingStrategy = packingStrategy
+	}
+
+	if spec.IngressClassName != nil {
+		config.IngressClassName = *spec.IngressClassName
+	}
+
+	if spec.MaxSlots != nil {
+		config.MaxSlots = int(*spec.MaxSlots)
+	}
+
+	if spec.SlotCounter != "" {
+		counter, err := ParseSlotCounter(slotCounterNames[spec.SlotCounter])
+		if err != nil {
+			config.Errors = append(config.Errors, fmt.Errorf("invalid slotCounter: %w", err))
+		} else {
+			config.SlotCounter = counter
+		}
+	}
+
+	config.Limits = BucketLimits{
+		MaxPaths:        int(spec.Limits.Paths),
+		MaxHosts:        int(spec.Limits.Hosts),
+		MaxCertificates: int(spec.Limits.Certificates),
+		MaxBackends:     int(spec.Limits.Backends),
+	}
+
+	if spec.TLS.WildcardIgnoreSelector != nil {
+		selector, err := metaV1.LabelSelectorAsSelector(spec.TLS.WildcardIgnoreSelector)
+		if err != nil {
+			config.Errors = append(config.Errors, fmt.Errorf("invalid tls.wildcardIgnoreSelector: %w", err))
+		} else {
+			config.UseWildcardTLSIgnore = selector
+		}
+	}
+
+	if spec.IngressSelector != nil {
+		selector, err := metaV1.LabelSelectorAsSelector(spec.IngressSelector)
+		if err != nil {
+			// an invalid selector must not pull every ingress in
+			config.IngressSelector = labels.Nothing()
+			config.Errors = append(config.Errors, fmt.Errorf("invalid ingressSelector: %w", err))
+		} else {
+			config.IngressSelector = selector
+		}
+	}
+
+	return config
+}
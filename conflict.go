@@ -0,0 +1,142 @@
+package ingress_merge
+
+import (
+	"strconv"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+type pathKey struct {
+	host     string
+	path     string
+	pathType networkingv1.PathType
+}
+
+// PathConflict describes a path declared by more than one source ingress,
+// Ingress is the source whose path is dropped from the result ingress.
+type PathConflict struct {
+	Ingress  *networkingv1.Ingress
+	Winner   *networkingv1.Ingress
+	Host     string
+	Path     string
+	PathType networkingv1.PathType
+}
+
+// ResolvePathConflicts finds the paths declared by more than one ingress,
+// keyed by host, path and path type. The ingress with the highest priority
+// keeps the path, ties are won by the oldest ingress.
+func ResolvePathConflicts(ingresses []networkingv1.Ingress) []PathConflict {
+	owners := map[pathKey]*networkingv1.Ingress{}
+	conflicts := []PathConflict{}
+
+	for i := range ingresses {
+		ingress := &ingresses[i]
+
+		for _, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+
+			for _, path := range rule.HTTP.Paths {
+				k := newPathKey(rule.Host, path)
+				owner, exists := owners[k]
+				if !exists {
+					owners[k] = ingress
+					continue
+				}
+
+				if owner.Name == ingress.Name {
+					continue
+				}
+
+				winner, loser := owner, ingress
+				if hasPrecedence(ingress, owner) {
+					winner, loser = ingress, owner
+					owners[k] = ingress
+				}
+
+				conflicts = append(conflicts, PathConflict{
+					Ingress:  loser,
+					Winner:   winner,
+					Host:     k.host,
+					Path:     k.path,
+					PathType: k.pathType,
+				})
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// withoutConflictingPaths returns the rules of the ingress without the paths
+// it lost to other ingresses, rules left without paths are dropped.
+func withoutConflictingPaths(ingress *networkingv1.Ingress, conflicts []PathConflict) []networkingv1.IngressRule {
+	dropped := map[pathKey]bool{}
+	for _, conflict := range conflicts {
+		if conflict.Ingress.Name == ingress.Name {
+			dropped[pathKey{conflict.Host, conflict.Path, conflict.PathType}] = true
+		}
+	}
+
+	if len(dropped) == 0 {
+		return ingress.Spec.Rules
+	}
+
+	rules := []networkingv1.IngressRule{}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			rules = append(rules, rule)
+			continue
+		}
+
+		paths := []networkingv1.HTTPIngressPath{}
+		for _, path := range rule.HTTP.Paths {
+			if !dropped[newPathKey(rule.Host, path)] {
+				paths = append(paths, path)
+			}
+		}
+
+		if len(paths) == 0 {
+			continue
+		}
+
+		rule = *rule.DeepCopy()
+		rule.HTTP.Paths = paths
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+func newPathKey(host string, path networkingv1.HTTPIngressPath) pathKey {
+	k := pathKey{
+		host:     host,
+		path:     path.Path,
+		pathType: networkingv1.PathTypeImplementationSpecific,
+	}
+	if path.PathType != nil {
+		k.pathType = *path.PathType
+	}
+
+	return k
+}
+
+// hasPrecedence tells whether a wins over b, by priority and then by age.
+func hasPrecedence(a, b *networkingv1.Ingress) bool {
+	priorityA, priorityB := ingressPriority(a), ingressPriority(b)
+	if priorityA != priorityB {
+		return priorityA > priorityB
+	}
+
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+
+	return a.Name < b.Name
+}
+
+func ingressPriority(ingress *networkingv1.Ingress) int {
+	priority, _ := strconv.Atoi(ingress.Annotations[PriorityAnnotation])
+	return priority
+}
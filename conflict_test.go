@@ -0,0 +1,93 @@
+package ingress_merge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestResolvePathConflicts(t *testing.T) {
+	older := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		ingresses []networkingv1.Ingress
+		losers    []string
+	}{
+		{
+			name: "no conflicts on different hosts",
+			ingresses: []networkingv1.Ingress{
+				createdAt(newTestIngress("a", "a.example.org", "/"), older),
+				createdAt(newTestIngress("b", "b.example.org", "/"), older),
+			},
+			losers: []string{},
+		},
+		{
+			name: "no conflicts on different paths",
+			ingresses: []networkingv1.Ingress{
+				createdAt(newTestIngress("a", "foo.example.org", "/"), older),
+				createdAt(newTestIngress("b", "foo.example.org", "/b"), older),
+			},
+			losers: []string{},
+		},
+		{
+			name: "highest priority wins",
+			ingresses: []networkingv1.Ingress{
+				createdAt(newTestIngress("a", "foo.example.org", "/"), older),
+				createdAt(withAnnotations(newTestIngress("b", "foo.example.org", "/"), map[string]string{PriorityAnnotation: "10"}), newer),
+			},
+			losers: []string{"a"},
+		},
+		{
+			name: "oldest wins on same priority",
+			ingresses: []networkingv1.Ingress{
+				createdAt(newTestIngress("a", "foo.example.org", "/"), newer),
+				createdAt(newTestIngress("b", "foo.example.org", "/"), older),
+			},
+			losers: []string{"a"},
+		},
+		{
+			name: "many losers",
+			ingresses: []networkingv1.Ingress{
+				createdAt(newTestIngress("a", "foo.example.org", "/"), older),
+				createdAt(newTestIngress("b", "foo.example.org", "/"), older),
+				createdAt(withAnnotations(newTestIngress("c", "foo.example.org", "/"), map[string]string{PriorityAnnotation: "5"}), newer),
+			},
+			losers: []string{"b", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			losers := []string{}
+			for _, conflict := range ResolvePathConflicts(tt.ingresses) {
+				losers = append(losers, conflict.Ingress.Name)
+			}
+			assert.Equal(t, tt.losers, losers)
+		})
+	}
+}
+
+func TestWithoutConflictingPaths(t *testing.T) {
+	created := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	ingresses := []networkingv1.Ingress{
+		createdAt(withAnnotations(newTestIngress("a", "foo.example.org", "/"), map[string]string{PriorityAnnotation: "1"}), created),
+		createdAt(newTestIngress("b", "foo.example.org", "/", "/b"), created),
+		createdAt(newTestIngress("c", "foo.example.org", "/b"), created),
+	}
+
+	conflicts := ResolvePathConflicts(ingresses)
+
+	assert.Equal(t, ingresses[0].Spec.Rules, withoutConflictingPaths(&ingresses[0], conflicts))
+
+	rules := withoutConflictingPaths(&ingresses[1], conflicts)
+	assert.Len(t, rules, 1)
+	assert.Len(t, rules[0].HTTP.Paths, 1)
+	assert.Equal(t, "/b", rules[0].HTTP.Paths[0].Path)
+	assert.Len(t, ingresses[1].Spec.Rules[0].HTTP.Paths, 2)
+
+	assert.Empty(t, withoutConflictingPaths(&ingresses[2], conflicts))
+}
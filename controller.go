@@ -2,23 +2,34 @@ package ingress_merge
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/ghodss/yaml"
 	"github.com/go-logr/logr"
 	multierror "github.com/hashicorp/go-multierror"
+	mergev1alpha1 "github.com/tsuru/ingress-merge/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
@@ -27,96 +38,255 @@ const (
 	FromConfigAnnotation   = "merge.ingress.kubernetes.io/from-config"
 	PriorityAnnotation     = "merge.ingress.kubernetes.io/priority"
 	ResultAnnotation       = "merge.ingress.kubernetes.io/result"
+	EmptySinceAnnotation   = "merge.ingress.kubernetes.io/empty-since"
+	DrainingAnnotation     = "merge.ingress.kubernetes.io/draining"
+	StatusAnnotation       = "merge.ingress.kubernetes.io/status"
 )
 
 const (
-	NameConfigKey           = "name"
-	LabelsConfigKey         = "labels"
-	AnnotationsConfigKey    = "annotations"
-	BackendConfigKey        = "backend"
-	UseWildcardTLSKey       = "use-wildcard-tls"
-	UseWildcardTLSIgnoreKey = "use-wildcard-tls-ignore"
-	wildcardTLSSuffix       = "-wildcard-tls"
+	NameConfigKey             = "name"
+	LabelsConfigKey           = "labels"
+	AnnotationsConfigKey      = "annotations"
+	BackendConfigKey          = "backend"
+	UseWildcardTLSKey         = "use-wildcard-tls"
+	UseWildcardTLSIgnoreKey   = "use-wildcard-tls-ignore"
+	IngressClassNameConfigKey = "ingressClassName"
+	PackingStrategyConfigKey  = "packing-strategy"
+	PackingLabelConfigKey     = "packing-affinity-label"
+	SlotCounterConfigKey      = "slot-counter"
+	MaxSlotsConfigKey         = "max-slots"
+	MaxPathsConfigKey         = "max-paths"
+	MaxHostsConfigKey         = "max-hosts"
+	MaxCertificatesConfigKey  = "max-certificates"
+	MaxBackendsConfigKey      = "max-backends"
+	wildcardTLSSuffix         = "-wildcard-tls"
+)
+
+const (
+	InvalidPriorityReason   = "InvalidPriority"
+	MissingConfigReason     = "MissingConfig"
+	ConfigMapNotFoundReason = "ConfigMapNotFound"
+	IngressClassLoopReason  = "IngressClassLoop"
+	InvalidConfigReason     = "InvalidConfig"
+	CreatedReason           = "Created"
+	UpdatedReason           = "Updated"
+	StatusPropagatedReason  = "StatusPropagated"
+	EmptyReason             = "Empty"
+	PathConflictReason      = "PathConflict"
+	UnschedulableHostReason = "UnschedulableHost"
+	DrainingReason          = "Draining"
+	IngressSelectorReason   = "IngressSelector"
+	ConfigNotWatchedReason  = "ConfigNotWatched"
 )
 
 var _ reconcile.Reconciler = &IngressReconciler{}
 
 type IngressReconciler struct {
 	client.Client
-	Log logr.Logger
+	Log      logr.Logger
+	Recorder record.EventRecorder
 
 	IngressClass         string
-	IngressSelector      string
-	ConfigMapSelector    string
+	IngressSelector      labels.Selector
+	ConfigMapSelector    labels.Selector
 	IngressMaxSlots      int
+	SlotCounter          SlotCounter
 	IngressWatchIgnore   []string
 	ConfigMapWatchIgnore []string
+
+	// ResultIngressGracePeriod is how long a result ingress without source
+	// ingresses is kept before being deleted.
+	ResultIngressGracePeriod time.Duration
+
+	// EnableIngressMerge makes IngressMerge resources usable as merge
+	// configuration, it requires the CRD to be installed.
+	EnableIngressMerge bool
+
+	// EnableBucketCompaction moves sources out of the least used result
+	// ingresses when the used slots fit into fewer result ingresses.
+	EnableBucketCompaction bool
+
+	// DryRun computes every merge but sends the writes with server-side
+	// dry-run, so the controller can run next to another one without
+	// changing anything. Its events are prefixed with "dry run: ".
+	DryRun bool
+
+	// MaxConcurrentReconciles is how many merge groups are reconciled at the
+	// same time, a group being never reconciled by two workers at once.
+	// Defaults to one.
+	MaxConcurrentReconciles int
+
+	// RateLimiter paces the requeues of merge groups, defaults to the
+	// controller-runtime one.
+	RateLimiter ratelimiter.RateLimiter
+
+	// SettleWindow holds a merge group back until its objects stopped
+	// changing for that long, so bursts of changes are merged into a single
+	// update of the result ingresses. Disabled when zero.
+	SettleWindow time.Duration
+
+	// MaxSettleDelay is how long a merge group changing continuously is held
+	// back at most, no limit when zero.
+	MaxSettleDelay time.Duration
+
+	// indexed is set once the ingress indexes are registered on the cache.
+	indexed bool
+
+	settler *groupSettler
 }
 
+// Reconcile merges the source ingresses of a merge group, the request being
+// named after the config of the group. A request without name reports the
+// source ingresses of the namespace that belong to no group.
 func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	ingress := &networkingv1.Ingress{}
+	if wait := r.settler.wait(req); wait > 0 {
+		r.Log.Info("merge group is still changing, waiting for it to settle",
+			"namespace", req.Namespace,
+			"config", req.Name,
+			"wait", wait,
+		)
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	if req.Name == "" {
+		return ctrl.Result{}, r.reportMissingConfigs(ctx, req.Namespace)
+	}
+
+	r.Log.Info("reconciling merge group",
+		"namespace", req.Namespace,
+		"config", req.Name,
+	)
 
-	err := r.Get(ctx, client.ObjectKey{
-		Namespace: req.Namespace,
-		Name:      req.Name,
-	}, ingress)
+	requeueAfter, err := r.reconcileGroup(ctx, req.Namespace, req.Name)
 	if err != nil {
-		if k8sErrors.IsNotFound(err) {
-			r.Log.Info("ingress has been deleted",
-				"name", req.Name,
-				"namespace", req.Namespace,
-			)
-			err = r.reconcileNamespace(ctx, req.Namespace)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-			return ctrl.Result{}, nil
-		}
-		r.Log.Error(err, "could not get ingress object")
 		return ctrl.Result{}, err
 	}
 
-	ingressClass := getIngressClass(ingress)
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
 
-	if ingress.Annotations[ResultAnnotation] == "true" {
-		r.Log.Info("reconciling cause the merged instance has been changed",
-			"namespace", req.Namespace,
-			"name", req.Name,
-		)
-	} else if ingressClass != r.IngressClass {
-		r.Log.Info("ingress does not match ingressClass, ignoring",
-			"ingress", req.String(),
-			"ingressClass", r.IngressClass)
-		return ctrl.Result{}, nil
+// namespaceMerges holds the source ingresses of a namespace grouped by the
+// name of their config, along with the result ingresses of the namespace.
+// The ingresses referencing a config but left out of its merge are kept in
+// skipped, to be reported on the status of the config.
+type namespaceMerges struct {
+	ingresses       map[string][]networkingv1.Ingress
+	configs         map[string]*MergeConfig
+	skipped         map[string][]mergev1alpha1.SkippedIngress
+	resultIngresses []networkingv1.Ingress
+}
+
+// resultIngressesOf returns the result ingresses created from a config.
+func (m *namespaceMerges) resultIngressesOf(configName string) []networkingv1.Ingress {
+	resultIngresses := []networkingv1.Ingress{}
+	for _, resultIngress := range m.resultIngresses {
+		if resultIngress.Annotations[FromConfigAnnotation] == configName {
+			resultIngresses = append(resultIngresses, resultIngress)
+		}
 	}
 
-	err = r.reconcileNamespace(ctx, req.Namespace)
+	return resultIngresses
+}
+
+// reconcileGroup merges the source ingresses of a config, and cleans up its
+// result ingresses once no source ingress references it anymore.
+func (r *IngressReconciler) reconcileGroup(ctx context.Context, ns, configName string) (time.Duration, error) {
+	merges, err := r.listMerges(ctx, ns, configName)
 	if err != nil {
-		return ctrl.Result{}, err
+		return 0, err
+	}
+
+	var (
+		errors       error
+		requeueAfter time.Duration
+	)
+
+	// the group has no source ingress to merge anymore
+	if _, exists := merges.ingresses[configName]; !exists {
+		deleteMergeMetrics(ns, configName)
+		setSkippedIngresses(ns, configName, merges.skipped[configName])
+
+		// the result ingresses of a deleted config are garbage collected
+		// through their owner reference, without being cleaned up
+		_, err := r.getMergeConfig(ctx, ns, configName)
+		if k8sErrors.IsNotFound(err) {
+			deleteConfigBucketSlots(ns, configName)
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	for configName, ingresses := range merges.ingresses {
+		configRequeueAfter, err := r.reconcileConfig(ctx, merges.configs[configName], ingresses, merges.resultIngressesOf(configName), merges.skipped[configName])
+		requeueAfter = minRequeueAfter(requeueAfter, configRequeueAfter)
+
+		if err != nil {
+			errors = multierror.Append(errors, err)
+		}
 	}
 
-	return ctrl.Result{}, nil
+	for _, resultIngress := range merges.resultIngresses {
+		configName := resultIngress.Annotations[FromConfigAnnotation]
+		if _, exists := merges.ingresses[configName]; exists {
+			continue
+		}
+
+		orphan, err := r.isOrphanResultIngress(ctx, &resultIngress)
+		if err != nil {
+			errors = multierror.Append(errors, err)
+			continue
+		}
+
+		if !orphan {
+			continue
+		}
+
+		resultRequeueAfter, err := r.cleanupResultIngress(ctx, resultIngress)
+		requeueAfter = minRequeueAfter(requeueAfter, resultRequeueAfter)
+
+		if err != nil {
+			errors = multierror.Append(errors, err)
+		}
+	}
+
+	return requeueAfter, errors
 }
 
-func (r *IngressReconciler) reconcileNamespace(ctx context.Context, ns string) error {
-	ingresses := &networkingv1.IngressList{}
-	err := r.Client.List(ctx, ingresses, &client.ListOptions{
-		Namespace: ns,
-	})
+// listMerges lists the source ingresses of a namespace grouped by config,
+// the ingresses left out of any merge are reported with warning events. A
+// group config name restricts the listing to the source and result ingresses
+// of that config.
+func (r *IngressReconciler) listMerges(ctx context.Context, ns, groupConfigName string) (*namespaceMerges, error) {
+	var (
+		ingresses []networkingv1.Ingress
+		err       error
+	)
+	if groupConfigName != "" {
+		ingresses, err = r.listIngresses(ctx, ns, r.ingressSelector(), configIndexField, groupConfigName)
+	} else {
+		ingresses, err = r.listIngresses(ctx, ns, r.ingressSelector(), ingressClassIndexField, r.IngressClass)
+	}
+	if err != nil {
+		return nil, err
+	}
 
+	resultIngresses, err := r.listResultIngresses(ctx, ns, groupConfigName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var (
-		mergeMap        = make(map[string][]networkingv1.Ingress)
-		configMaps      = make(map[string]corev1.ConfigMap)
-		resultIngresses = []networkingv1.Ingress{}
+		mergeMap = make(map[string][]networkingv1.Ingress)
+		configs  = make(map[string]*MergeConfig)
+		skipped  = make(map[string][]mergev1alpha1.SkippedIngress)
 	)
 
-	for _, ingress := range ingresses.Items {
+	for _, ingress := range ingresses {
 		if ingress.Annotations[ResultAnnotation] == "true" {
-			resultIngresses = append(resultIngresses, ingress)
+			continue
+		}
+
+		if groupConfigName != "" && ingress.Annotations[ConfigAnnotation] != groupConfigName {
 			continue
 		}
 
@@ -136,288 +306,670 @@ func (r *IngressReconciler) reconcileNamespace(ctx context.Context, ns string) e
 					"namespace", ingress.Namespace,
 					"annotation", PriorityAnnotation,
 				)
-				// TODO: emit error event on ingress that priority must be integer
-
+				r.Recorder.Eventf(&ingress, corev1.EventTypeWarning, InvalidPriorityReason,
+					"annotation %s must be an integer, got %q", PriorityAnnotation, priorityString)
+
+				if configName, exists := ingress.Annotations[ConfigAnnotation]; exists {
+					skipped[configName] = append(skipped[configName], mergev1alpha1.SkippedIngress{
+						Name:   ingress.Name,
+						Reason: InvalidPriorityReason,
+					})
+				}
 				continue
 			}
 		}
 
-		configMapName, exists := ingress.Annotations[ConfigAnnotation]
+		configName, exists := ingress.Annotations[ConfigAnnotation]
 		if !exists {
-			r.Log.Error(nil, "ingress is missing annotation",
-				"ingress", ingress.Name,
-				"namespace", ingress.Namespace,
-				"annotation", ConfigAnnotation,
-			)
-			// TODO: emit error event on ingress that no config map name is set
+			r.reportMissingConfig(&ingress)
 			continue
 		}
 
-		configMap, exists := configMaps[configMapName]
+		config, exists := configs[configName]
 
 		if !exists {
-			err := r.Get(ctx, client.ObjectKey{
-				Namespace: ingress.Namespace,
-				Name:      configMapName,
-			}, &configMap)
+			config, err = r.getMergeConfig(ctx, ns, configName)
 
 			if err != nil {
 				if k8sErrors.IsNotFound(err) {
-					r.Log.Error(err, "configMap is not found", "name", configMapName, "ns", ns)
+					r.Log.Error(err, "configMap is not found", "name", configName, "ns", ns)
+					r.Recorder.Eventf(&ingress, corev1.EventTypeWarning, ConfigMapNotFoundReason,
+						"configmap %s referenced by annotation %s is not found", configName, ConfigAnnotation)
+					skipped[configName] = append(skipped[configName], mergev1alpha1.SkippedIngress{
+						Name:   ingress.Name,
+						Reason: ConfigMapNotFoundReason,
+					})
 					continue
 				}
 
-				return err
+				return nil, err
+			}
+
+			if config == nil {
+				r.Log.Info("configMap does not match selector or is ignored", "name", configName, "ns", ns)
+				skipped[configName] = append(skipped[configName], mergev1alpha1.SkippedIngress{
+					Name:   ingress.Name,
+					Reason: ConfigNotWatchedReason,
+				})
+				continue
 			}
 
-			configMaps[configMapName] = configMap
+			configs[configName] = config
+		}
+
+		if !config.IngressSelector.Matches(labels.Set(ingress.Labels)) {
+			r.Log.Info("ingress does not match the ingress selector of its config, ignoring",
+				"ingress", ingress.Name,
+				"namespace", ingress.Namespace,
+				"config", configName,
+			)
+			skipped[configName] = append(skipped[configName], mergev1alpha1.SkippedIngress{
+				Name:   ingress.Name,
+				Reason: IngressSelectorReason,
+			})
+			continue
 		}
 
-		mergeMap[configMapName] = append(mergeMap[configMapName], ingress)
+		mergeMap[configName] = append(mergeMap[configName], ingress)
 	}
 
-	var errors error
+	return &namespaceMerges{
+		ingresses:       mergeMap,
+		configs:         configs,
+		skipped:         skipped,
+		resultIngresses: resultIngresses,
+	}, nil
+}
 
-	for configMapName, ingresses := range mergeMap {
-		currentResultIngresses := []networkingv1.Ingress{}
+// nameTaken tells whether an ingress of a namespace, whatever its selector
+// or ingress class, already has a name. Names are looked up one at a time,
+// only when a new result ingress is named.
+func (r *IngressReconciler) nameTaken(ctx context.Context, ns string) NameTaken {
+	return func(name string) (bool, error) {
+		err := r.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, &networkingv1.Ingress{})
+		if k8sErrors.IsNotFound(err) {
+			return false, nil
+		}
 
-		for _, resultIngress := range resultIngresses {
-			if resultIngress.Annotations[FromConfigAnnotation] == configMapName {
-				currentResultIngresses = append(currentResultIngresses, resultIngress)
-			}
+		return err == nil, err
+	}
+}
+
+// reportMissingConfigs reports the source ingresses of a namespace without
+// config annotation, which belong to no merge group.
+func (r *IngressReconciler) reportMissingConfigs(ctx context.Context, ns string) error {
+	ingresses, err := r.listIngresses(ctx, ns, r.ingressSelector(), ingressClassIndexField, r.IngressClass)
+	if err != nil {
+		return err
+	}
+
+	skipped := []mergev1alpha1.SkippedIngress{}
+	for _, ingress := range ingresses {
+		if ingress.Annotations[ResultAnnotation] == "true" {
+			continue
 		}
 
-		configMap := configMaps[configMapName]
-		err = r.reconcileConfigMap(ctx, configMap, ingresses, currentResultIngresses)
+		if getIngressClass(&ingress) != r.IngressClass || r.isIgnored(&ingress) {
+			continue
+		}
 
-		if err != nil {
-			errors = multierror.Append(errors, err)
+		if _, exists := ingress.Annotations[ConfigAnnotation]; !exists {
+			r.reportMissingConfig(&ingress)
+			skipped = append(skipped, mergev1alpha1.SkippedIngress{
+				Name:   ingress.Name,
+				Reason: MissingConfigReason,
+			})
 		}
 	}
 
-	return errors
+	setSkippedIngresses(ns, "", skipped)
+
+	return nil
 }
 
-func (r *IngressReconciler) reconcileConfigMap(ctx context.Context, configMap corev1.ConfigMap, ingresses, currentResultIngresses []networkingv1.Ingress) error {
-	sort.Slice(ingresses, func(i, j int) bool {
-		var (
-			a         = ingresses[i]
-			b         = ingresses[j]
-			priorityA = 0
-			priorityB = 0
-		)
+func (r *IngressReconciler) reportMissingConfig(ingress *networkingv1.Ingress) {
+	r.Log.Error(nil, "ingress is missing annotation",
+		"ingress", ingress.Name,
+		"namespace", ingress.Namespace,
+		"annotation", ConfigAnnotation,
+	)
+	r.Recorder.Eventf(ingress, corev1.EventTypeWarning, MissingConfigReason,
+		"annotation %s is missing", ConfigAnnotation)
+}
 
-		if priorityString, exits := a.Annotations[PriorityAnnotation]; exits {
-			priorityA, _ = strconv.Atoi(priorityString)
+// getMergeConfig returns the configuration referenced by the config
+// annotation, an IngressMerge takes precedence over a ConfigMap of the same
+// name. It returns nil when the ConfigMap is not watched by this controller.
+func (r *IngressReconciler) getMergeConfig(ctx context.Context, ns, name string) (*MergeConfig, error) {
+	key := client.ObjectKey{
+		Namespace: ns,
+		Name:      name,
+	}
+
+	if r.EnableIngressMerge {
+		var ingressMerge mergev1alpha1.IngressMerge
+		err := r.Get(ctx, key, &ingressMerge)
+		if err == nil {
+			return r.withNamespaceLabels(ctx, ConfigFromIngressMerge(&ingressMerge))
 		}
 
-		if priorityString, exits := b.Annotations[PriorityAnnotation]; exits {
-			priorityB, _ = strconv.Atoi(priorityString)
+		if !k8sErrors.IsNotFound(err) {
+			return nil, err
 		}
+	}
 
-		if priorityA > priorityB {
-			return true
-		} else if priorityA < priorityB {
-			return false
-		} else {
-			return a.Name < b.Name
+	var configMap corev1.ConfigMap
+	err := r.Get(ctx, key, &configMap)
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.isConfigMapWatched(&configMap) {
+		return nil, nil
+	}
+
+	return r.withNamespaceLabels(ctx, ConfigFromConfigMap(&configMap))
+}
+
+// withNamespaceLabels gives the label affinity packing strategy of a config
+// the labels of its namespace, where its source ingresses are.
+func (r *IngressReconciler) withNamespaceLabels(ctx context.Context, config *MergeConfig) (*MergeConfig, error) {
+	strategy, ok := config.PackingStrategy.(LabelAffinityPacking)
+	if !ok {
+		return config, nil
+	}
+
+	var namespace corev1.Namespace
+	err := r.Get(ctx, client.ObjectKey{Name: config.Namespace()}, &namespace)
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return nil, err
+	}
+	if _, exists := namespace.Labels[strategy.Label]; !exists {
+		r.Log.Info("namespace has no packing affinity label, packing its ingresses as best fit decreasing",
+			"ns", config.Namespace(), "label", strategy.Label)
+	}
+
+	strategy.NamespaceLabels = map[string]map[string]string{
+		config.Namespace(): namespace.Labels,
+	}
+	config.PackingStrategy = strategy
+
+	return config, nil
+}
+
+// isOrphanResultIngress tells whether no source ingress references the
+// config of the result ingress anymore. Sources outside of the ingress
+// selector and configmaps outside of the configmap selector are taken into
+// account, so results managed by another shard are left alone.
+func (r *IngressReconciler) isOrphanResultIngress(ctx context.Context, resultIngress *networkingv1.Ingress) (bool, error) {
+	configName := resultIngress.Annotations[FromConfigAnnotation]
+
+	config, err := r.getMergeConfig(ctx, resultIngress.Namespace, configName)
+	if err == nil && config == nil {
+		return false, nil
+	}
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return false, err
+	}
+
+	ingresses, err := r.listIngresses(ctx, resultIngress.Namespace, nil, configIndexField, configName)
+	if err != nil {
+		return false, err
+	}
+
+	for _, ingress := range ingresses {
+		if ingress.Annotations[ResultAnnotation] == "true" {
+			continue
 		}
-	})
 
-	buckets := GenerateIngressBuckets(ingresses, currentResultIngresses, r.IngressMaxSlots)
-	var errors error
+		if ingress.Annotations[ConfigAnnotation] != configName {
+			continue
+		}
+
+		// sources skipped because of errors still hold on to the result
+		if getIngressClass(&ingress) == r.IngressClass && !r.isIgnored(&ingress) {
+			return false, nil
+		}
+	}
 
-	for _, bucket := range buckets {
-		err := r.reconcileIngressBucket(ctx, configMap, bucket)
+	return true, nil
+}
 
+// cleanupResultIngress deletes a result ingress without source ingresses once
+// it has been empty for the grace period, and returns how long to wait before
+// checking it again.
+func (r *IngressReconciler) cleanupResultIngress(ctx context.Context, resultIngress networkingv1.Ingress) (time.Duration, error) {
+	if r.ResultIngressGracePeriod > 0 {
+		emptySince, err := time.Parse(time.RFC3339, resultIngress.Annotations[EmptySinceAnnotation])
 		if err != nil {
-			errors = multierror.Append(errors, err)
+			patch := client.MergeFrom(resultIngress.DeepCopy())
+			if resultIngress.Annotations == nil {
+				resultIngress.Annotations = make(map[string]string)
+			}
+			resultIngress.Annotations[EmptySinceAnnotation] = time.Now().UTC().Format(time.RFC3339)
+
+			// the source ingresses left, only the config holds on to the
+			// result ingress during the grace period
+			resultIngress.OwnerReferences = withoutIngressOwners(resultIngress.OwnerReferences)
+
+			err = r.Patch(ctx, &resultIngress, patch, client.FieldOwner(UpdateFieldManager))
+			if err != nil {
+				r.Log.Error(err, "could not mark ingress as empty",
+					"namespace", resultIngress.Namespace,
+					"name", resultIngress.Name,
+				)
+				return 0, err
+			}
+
+			r.Recorder.Eventf(&resultIngress, corev1.EventTypeNormal, EmptyReason,
+				"no source ingresses left, deleting after %s", r.ResultIngressGracePeriod)
+			return r.ResultIngressGracePeriod, nil
+		}
+
+		if remaining := r.ResultIngressGracePeriod - time.Since(emptySince); remaining > 0 {
+			return remaining, nil
 		}
 	}
 
-	return errors
+	err := r.Delete(ctx, &resultIngress)
+	observeOperation(deleteOperation, err)
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		r.Log.Error(err, "could not delete empty ingress",
+			"namespace", resultIngress.Namespace,
+			"name", resultIngress.Name,
+		)
+		return 0, err
+	}
+
+	r.Log.Info("Deleted empty merged ingress",
+		"namespace", resultIngress.Namespace,
+		"name", resultIngress.Name)
+
+	deleteBucketSlots(resultIngress.Namespace, resultIngress.Annotations[FromConfigAnnotation], resultIngress.Name)
+
+	return 0, nil
 }
 
-func (r *IngressReconciler) reconcileIngressBucket(ctx context.Context, configMap corev1.ConfigMap, bucket *IngressBucket) error {
+// listResultIngresses lists the ingresses created by the controller, which do
+// not necessarily match the ingress selector used for source ingresses. A
+// config name restricts the listing to the result ingresses of that config.
+func (r *IngressReconciler) listResultIngresses(ctx context.Context, ns, configName string) ([]networkingv1.Ingress, error) {
+	field := ""
+	if configName != "" {
+		field = fromConfigIndexField
+	}
 
-	var (
-		err             error
-		ownerReferences []metaV1.OwnerReference
-		tls             []networkingv1.IngressTLS
-		rules           []networkingv1.IngressRule
-		useWildcardTLS  bool            = configMap.Data[UseWildcardTLSKey] == "true"
-		wildcardDomains map[string]bool = make(map[string]bool)
-	)
+	ingresses, err := r.listIngresses(ctx, ns, nil, field, configName)
+	if err != nil {
+		return nil, err
+	}
 
-	useWildcardTLSIgnore := labels.Nothing()
-	useWildcardTLSIgnoreString := configMap.Data[UseWildcardTLSIgnoreKey]
-	if useWildcardTLSIgnoreString != "" {
-		useWildcardTLSIgnore, err = parseLabels(useWildcardTLSIgnoreString)
-		if err != nil {
-			return err
+	resultIngresses := []networkingv1.Ingress{}
+	for _, ingress := range ingresses {
+		if ingress.Annotations[ResultAnnotation] != "true" {
+			continue
+		}
+
+		if configName != "" && ingress.Annotations[FromConfigAnnotation] != configName {
+			continue
 		}
+
+		resultIngresses = append(resultIngresses, ingress)
 	}
 
-	for _, ingress := range bucket.Ingresses {
-		ownerReferences = append(ownerReferences, metaV1.OwnerReference{
-			APIVersion: ingress.APIVersion,
-			Kind:       "Ingress",
-			Name:       ingress.Name,
-			UID:        ingress.UID,
-		})
-
-	rules:
-		for _, r := range ingress.Spec.Rules {
-			for _, s := range rules {
-				if r.Host == s.Host {
-					s.HTTP.Paths = append(s.HTTP.Paths, r.HTTP.Paths...)
-					continue rules
-				}
-			}
+	return resultIngresses, nil
+}
+
+func (r *IngressReconciler) reconcileConfig(ctx context.Context, config *MergeConfig, ingresses, currentResultIngresses []networkingv1.Ingress, skipped []mergev1alpha1.SkippedIngress) (time.Duration, error) {
+	status := mergev1alpha1.IngressMergeStatus{
+		SkippedIngresses: skipped,
+	}
 
-			rules = append(rules, *r.DeepCopy())
+	if !r.checkConfig(config, ingresses) {
+		for _, ingress := range ingresses {
+			status.SkippedIngresses = append(status.SkippedIngresses, mergev1alpha1.SkippedIngress{
+				Name:   ingress.Name,
+				Reason: IngressClassLoopReason,
+			})
 		}
+		setSkippedIngresses(config.Namespace(), config.Name(), status.SkippedIngresses)
 
-		if useWildcardTLS {
-			if useWildcardTLSIgnore.Matches(labels.Set(ingress.Labels)) {
-				continue
-			}
-			wildcardDomains = mergeWildcardDomains(wildcardDomains, ingress.Spec.Rules)
-		} else {
-			tls = append(tls, ingress.Spec.TLS...)
+		return 0, r.updateConfigStatus(ctx, config, status,
+			fmt.Errorf("%s %s sets the result ingress class to %s, which is the merge ingress class", strings.ToLower(config.Kind), config.Name(), r.IngressClass))
+	}
+
+	plan, err := PlanMerge(config, ingresses, currentResultIngresses, r.nameTaken(ctx, config.Namespace()), config.Capacity(r.IngressMaxSlots, r.SlotCounter), r.EnableBucketCompaction)
+	if err != nil {
+		return 0, err
+	}
+	maxSlots := plan.Capacity.MaxSlots
+
+	for _, group := range plan.Unschedulable {
+		names := []string{}
+		for _, ingress := range group {
+			names = append(names, ingress.Name)
 		}
+
+		exceeded := plan.Capacity.exceeded(group)
+
+		r.Log.Error(nil, "ingresses sharing hosts need more than a result ingress has, merging them together anyway",
+			"namespace", config.Namespace(),
+			"ingresses", names,
+			"exceeded", exceeded,
+		)
+		r.eventOnIngresses(group, corev1.EventTypeWarning, UnschedulableHostReason,
+			"ingresses %s share hosts and need more than a result ingress has together: %s",
+			strings.Join(names, ", "), strings.Join(exceeded, ", "))
 	}
 
-	if useWildcardTLS {
-		tls = append(tls, wildcardTLSEntry(wildcardDomains, bucket))
+	for _, bucket := range plan.Drained {
+		r.Log.Info("draining merged ingress into the other ones",
+			"namespace", config.Namespace(),
+			"name", bucket.DestinationIngress.Name,
+			"ingresses", len(bucket.Ingresses),
+		)
+		r.Recorder.Eventf(bucket.DestinationIngress, corev1.EventTypeNormal, DrainingReason,
+			"moving %d ingresses to other result ingresses of %s %s", len(bucket.Ingresses), strings.ToLower(config.Kind), config.Name())
 	}
 
 	var (
-		labels      map[string]string
-		annotations map[string]string
-		backend     *networkingv1.IngressBackend
+		errors       error
+		requeueAfter time.Duration
 	)
 
-	if dataLabels, exists := configMap.Data[LabelsConfigKey]; exists {
-		if err := yaml.Unmarshal([]byte(dataLabels), &labels); err != nil {
-			labels = nil
-			r.Log.Error(err, "Could unmarshal labels from configmap",
-				"namespace", configMap.Namespace,
-				"configmap", configMap.Name,
-			)
+	for _, bucket := range plan.Buckets {
+		if bucket.DestinationIngress != nil && len(bucket.Ingresses) == 0 {
+			bucketRequeueAfter, err := r.cleanupResultIngress(ctx, *bucket.DestinationIngress)
+			requeueAfter = minRequeueAfter(requeueAfter, bucketRequeueAfter)
+
+			if err != nil {
+				errors = multierror.Append(errors, err)
+			}
+			continue
+		}
+
+		name := plan.Names[bucket]
+		status.Buckets = append(status.Buckets, bucketStatus(bucket, name))
+		setBucketSlots(config.Namespace(), config.Name(), name, maxSlots-bucket.FreeSlots, maxSlots)
+
+		conflicts, err := r.reconcileIngressBucket(ctx, config, bucket, name)
+		for _, conflict := range conflicts {
+			status.PathConflicts = append(status.PathConflicts, mergev1alpha1.PathConflictStatus{
+				Name:     conflict.Ingress.Name,
+				Winner:   conflict.Winner.Name,
+				Host:     conflict.Host,
+				Path:     conflict.Path,
+				PathType: conflict.PathType,
+			})
+		}
+
+		if err != nil {
+			errors = multierror.Append(errors, err)
 		}
 	}
 
-	if dataAnnotations, exists := configMap.Data[AnnotationsConfigKey]; exists {
-		if err := yaml.Unmarshal([]byte(dataAnnotations), &annotations); err != nil {
-			annotations = nil
-			r.Log.Error(err, "Could unmarshal annotations from configmap",
-				"namespace", configMap.Namespace,
-				"configmap", configMap.Name,
-			)
+	sourceIngressesMetric.WithLabelValues(config.Namespace(), config.Name()).Set(float64(len(ingresses)))
+	resultIngressesMetric.WithLabelValues(config.Namespace(), config.Name()).Set(float64(len(status.Buckets)))
+	setSkippedIngresses(config.Namespace(), config.Name(), status.SkippedIngresses)
+
+	err = r.updateConfigStatus(ctx, config, status, errors)
+	if err != nil {
+		errors = multierror.Append(errors, err)
+	}
+
+	return requeueAfter, errors
+}
+
+// checkConfig reports the errors of a config with events on its source
+// ingresses, and tells whether the config can be merged.
+func (r *IngressReconciler) checkConfig(config *MergeConfig, ingresses []networkingv1.Ingress) bool {
+	for _, err := range config.Errors {
+		r.Log.Error(err, "invalid merge configuration",
+			"namespace", config.Namespace(),
+			"kind", config.Kind,
+			"name", config.Name(),
+		)
+		r.eventOnIngresses(ingresses, corev1.EventTypeWarning, InvalidConfigReason,
+			"invalid %s %s: %v", strings.ToLower(config.Kind), config.Name(), err)
+	}
+
+	if config.LoopsInto(r.IngressClass) {
+		r.Log.Error(nil, "trying to create merged ingress of merge ingress class, you have to change ingress class",
+			"namespace", config.Namespace(),
+			"kind", config.Kind,
+			"name", config.Name(),
+			"ingress_class", r.IngressClass,
+		)
+		r.eventOnIngresses(ingresses, corev1.EventTypeWarning, IngressClassLoopReason,
+			"%s %s sets the result ingress class to %s, which is the merge ingress class", strings.ToLower(config.Kind), config.Name(), r.IngressClass)
+		return false
+	}
+
+	return true
+}
+
+// updateConfigStatus reports the outcome of a merge on the status of an
+// IngressMerge, or as JSON in the status annotation of a ConfigMap.
+func (r *IngressReconciler) updateConfigStatus(ctx context.Context, config *MergeConfig, status mergev1alpha1.IngressMergeStatus, mergeErr error) error {
+	sort.Slice(status.Buckets, func(i, j int) bool {
+		return status.Buckets[i].Name < status.Buckets[j].Name
+	})
+	for _, bucket := range status.Buckets {
+		status.ResultIngresses = append(status.ResultIngresses, bucket.Name)
+	}
+	sort.Slice(status.PathConflicts, func(i, j int) bool {
+		a, b := status.PathConflicts[i], status.PathConflicts[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
 		}
+		return a.PathType < b.PathType
+	})
 
-		if annotations[IngressClassAnnotation] == r.IngressClass {
-			r.Log.Error(nil, "trying to create merged ingress of merge ingress class, you have to change ingress class",
-				"namespace", configMap.Namespace,
-				"configmap", configMap.Name,
-				"ingress_class", r.IngressClass,
-			)
+	now := metaV1.Now()
+	status.LastReconcileTime = &now
+
+	if mergeErr != nil {
+		status.LastError = mergeErr.Error()
+	} else if len(config.Errors) > 0 {
+		status.LastError = config.Errors[0].Error()
+	}
+
+	switch object := config.Object.(type) {
+	case *mergev1alpha1.IngressMerge:
+		return r.updateIngressMergeStatus(ctx, object, status, config.Errors, mergeErr)
+	case *corev1.ConfigMap:
+		return r.updateConfigMapStatus(ctx, object, status)
+	}
+
+	return nil
+}
+
+func (r *IngressReconciler) updateIngressMergeStatus(ctx context.Context, ingressMerge *mergev1alpha1.IngressMerge, status mergev1alpha1.IngressMergeStatus, configErrors []error, mergeErr error) error {
+	condition := metaV1.Condition{
+		Type:               mergev1alpha1.ReadyCondition,
+		Status:             metaV1.ConditionTrue,
+		ObservedGeneration: ingressMerge.Generation,
+		Reason:             "Merged",
+		Message:            fmt.Sprintf("merged into %d ingresses", len(status.ResultIngresses)),
+	}
+
+	if mergeErr != nil {
+		condition.Status = metaV1.ConditionFalse
+		condition.Reason = "MergeFailed"
+		condition.Message = mergeErr.Error()
+	} else if len(configErrors) > 0 {
+		condition.Status = metaV1.ConditionFalse
+		condition.Reason = InvalidConfigReason
+		condition.Message = configErrors[0].Error()
+	}
+
+	status.ObservedGeneration = ingressMerge.Generation
+	status.Conditions = append([]metaV1.Condition(nil), ingressMerge.Status.Conditions...)
+	meta.SetStatusCondition(&status.Conditions, condition)
+	if !isStatusChanged(ingressMerge.Status, status) {
+		return nil
+	}
+	ingressMerge.Status = status
+
+	err := r.Status().Update(ctx, ingressMerge, client.FieldOwner(UpdateFieldManager))
+	if err != nil {
+		r.Log.Error(err, "could not update status of ingressmerge",
+			"namespace", ingressMerge.Namespace,
+			"name", ingressMerge.Name,
+		)
+	}
+
+	return err
+}
+
+// updateConfigMapStatus writes the status in an annotation, ConfigMaps having
+// no status of their own.
+func (r *IngressReconciler) updateConfigMapStatus(ctx context.Context, configMap *corev1.ConfigMap, status mergev1alpha1.IngressMergeStatus) error {
+	if data, exists := configMap.Annotations[StatusAnnotation]; exists {
+		current := mergev1alpha1.IngressMergeStatus{}
+		if json.Unmarshal([]byte(data), &current) == nil && !isStatusChanged(current, status) {
 			return nil
 		}
 	}
 
-	if annotations == nil {
-		annotations = make(map[string]string)
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
 	}
-	annotations[FromConfigAnnotation] = configMap.Name
-	annotations[ResultAnnotation] = "true"
 
-	if dataBackend, exists := configMap.Data[BackendConfigKey]; exists {
-		if err := yaml.Unmarshal([]byte(dataBackend), &backend); err != nil {
-			backend = nil
-			r.Log.Error(err, "Could not unmarshal backend from config",
-				"namespace", configMap.Namespace,
-				"config_map", configMap.Name)
-		}
+	patch := client.MergeFrom(configMap.DeepCopy())
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
 	}
+	configMap.Annotations[StatusAnnotation] = string(data)
 
-	ingressClassName := configMap.Data["ingressClassName"]
-	var ingressClassNameRef *string
-	if ingressClassName != "" {
-		ingressClassNameRef = &ingressClassName
+	err = r.Patch(ctx, configMap, patch, client.FieldOwner(UpdateFieldManager))
+	if err != nil {
+		r.Log.Error(err, "could not update status of configmap",
+			"namespace", configMap.Namespace,
+			"name", configMap.Name,
+		)
 	}
 
-	mergedIngress := &networkingv1.Ingress{
-		ObjectMeta: metaV1.ObjectMeta{
-			Namespace:       configMap.Namespace,
-			Labels:          labels,
-			Annotations:     annotations,
-			OwnerReferences: ownerReferences,
-		},
-		Spec: networkingv1.IngressSpec{
-			IngressClassName: ingressClassNameRef,
-			DefaultBackend:   backend,
-			TLS:              tls,
-			Rules:            rules,
-		},
+	return err
+}
+
+// isStatusChanged tells whether a status differs from the current one other
+// than by its reconcile time, which changes on every merge.
+func isStatusChanged(current, status mergev1alpha1.IngressMergeStatus) bool {
+	current.LastReconcileTime = nil
+	status.LastReconcileTime = nil
+
+	return !equality.Semantic.DeepEqual(current, status)
+}
+
+// bucketStatus describes a result ingress and its source ingresses.
+func bucketStatus(bucket *IngressBucket, name string) mergev1alpha1.ResultIngressStatus {
+	sources := []string{}
+	for _, ingress := range bucket.Ingresses {
+		sources = append(sources, ingress.Name)
+	}
+	sort.Strings(sources)
+
+	return mergev1alpha1.ResultIngressStatus{
+		Name:      name,
+		Sources:   sources,
+		FreeSlots: int32(bucket.FreeSlots),
+	}
+}
+
+// reconcileIngressBucket creates or updates the result ingress of a bucket,
+// and returns the paths of its source ingresses lost to other ones.
+func (r *IngressReconciler) reconcileIngressBucket(ctx context.Context, config *MergeConfig, bucket *IngressBucket, name string) ([]PathConflict, error) {
+	mergedIngress, conflicts := BuildResultIngress(config, bucket, name, r.ResultIngressGracePeriod > 0)
+	for _, conflict := range conflicts {
+		r.Log.Info("ingress path conflicts with another ingress, skipping path",
+			"namespace", conflict.Ingress.Namespace,
+			"ingress", conflict.Ingress.Name,
+			"winner", conflict.Winner.Name,
+			"host", conflict.Host,
+			"path", conflict.Path,
+		)
+		r.Recorder.Eventf(conflict.Ingress, corev1.EventTypeWarning, PathConflictReason,
+			"path %s%s (%s) is also declared by ingress %s, which takes precedence",
+			conflict.Host, conflict.Path, conflict.PathType, conflict.Winner.Name)
 	}
 
+	var err error
 	changed := false
 
 	if bucket.DestinationIngress == nil {
-		suffix := string(uuid.NewUUID())[0:7]
-		mergedIngress.Name = configMap.Name + "-" + suffix
 		changed = true
 
-		if useWildcardTLS && len(mergedIngress.Spec.TLS) > 0 {
-			mergedIngress.Spec.TLS[0].SecretName = mergedIngress.Name + wildcardTLSSuffix
-		}
-
-		err = r.Create(ctx, mergedIngress)
+		err = r.createResultIngress(ctx, mergedIngress)
+		observeOperation(createOperation, err)
 		if err != nil {
 			r.Log.Error(err, "could not create ingress", "ingress", mergedIngress.Name, "namespace", mergedIngress.Namespace)
-			return err
+			return conflicts, err
 		}
 
 		r.Log.Info("Created merged ingress",
 			"namespace", mergedIngress.Namespace,
 			"name", mergedIngress.Name)
+		r.Recorder.Eventf(mergedIngress, corev1.EventTypeNormal, CreatedReason,
+			"merged %d ingresses from %s %s", len(bucket.Ingresses), strings.ToLower(config.Kind), config.Name())
 	} else {
-		mergedIngress.Name = bucket.DestinationIngress.Name
-
 		var existingMergedIngress networkingv1.Ingress
 		err := r.Get(ctx, client.ObjectKey{
-			Namespace: configMap.Namespace,
+			Namespace: config.Namespace(),
 			Name:      mergedIngress.Name,
 		}, &existingMergedIngress)
 
 		if err != nil {
-			return err
+			return conflicts, err
 		}
 
 		if r.hasIngressChanged(&existingMergedIngress, mergedIngress) {
 			changed = true
 
-			mergedIngress.ObjectMeta.ResourceVersion = existingMergedIngress.ObjectMeta.ResourceVersion
-			err = r.Update(ctx, mergedIngress)
+			err = r.applyResultIngress(ctx, mergedIngress, &existingMergedIngress)
+			observeOperation(updateOperation, err)
 
 			if err != nil {
 				r.Log.Error(err, "could not update ingress",
 					"namespace", mergedIngress.Namespace,
 					"name", mergedIngress.Name,
 				)
-				return err
+				return conflicts, err
 			}
 
 			r.Log.Info("Updated merged ingress",
 				"namespace", mergedIngress.Namespace,
 				"name", mergedIngress.Name)
+			r.Recorder.Eventf(mergedIngress, corev1.EventTypeNormal, UpdatedReason,
+				"merged %d ingresses from %s %s", len(bucket.Ingresses), strings.ToLower(config.Kind), config.Name())
 
 			mergedIngress.Status = existingMergedIngress.Status
 		} else {
 			mergedIngress = &existingMergedIngress
 		}
+
+		// the annotation is not applied, it is set by a patch when the result
+		// ingress is left empty
+		if _, exists := mergedIngress.Annotations[EmptySinceAnnotation]; exists {
+			patch := client.MergeFrom(mergedIngress.DeepCopy())
+			delete(mergedIngress.Annotations, EmptySinceAnnotation)
+
+			err = r.Patch(ctx, mergedIngress, patch, client.FieldOwner(UpdateFieldManager))
+			if err != nil {
+				r.Log.Error(err, "could not unmark ingress as empty",
+					"namespace", mergedIngress.Namespace,
+					"name", mergedIngress.Name,
+				)
+				return conflicts, err
+			}
+		}
 	}
 
 	for _, ingress := range bucket.Ingresses {
@@ -427,6 +979,11 @@ func (r *IngressReconciler) reconcileIngressBucket(ctx context.Context, configMa
 			continue
 		}
 
+		// the status still comes from the draining bucket serving it
+		if bucket.Incoming[ingress.Name] {
+			continue
+		}
+
 		if reflect.DeepEqual(ingress.Status, mergedIngress.Status) {
 			continue
 		}
@@ -434,7 +991,8 @@ func (r *IngressReconciler) reconcileIngressBucket(ctx context.Context, configMa
 		mergedIngress.Status.DeepCopyInto(&ingress.Status)
 
 		changed = true
-		err = r.Status().Update(ctx, &ingress)
+		err = r.Status().Update(ctx, &ingress, client.FieldOwner(UpdateFieldManager))
+		observeOperation(statusPropagationOperation, err)
 		if err != nil {
 			r.Log.Error(
 				err, "Could not update status of ingress",
@@ -449,6 +1007,8 @@ func (r *IngressReconciler) reconcileIngressBucket(ctx context.Context, configMa
 			"from_ingress", mergedIngress.Name,
 			"to_ingress", ingress.Name,
 		)
+		r.Recorder.Eventf(mergedIngress, corev1.EventTypeNormal, StatusPropagatedReason,
+			"propagated status to ingress %s", ingress.Name)
 	}
 
 	if !changed {
@@ -457,7 +1017,13 @@ func (r *IngressReconciler) reconcileIngressBucket(ctx context.Context, configMa
 			"ingress", mergedIngress.Name)
 	}
 
-	return nil
+	return conflicts, nil
+}
+
+func (r *IngressReconciler) eventOnIngresses(ingresses []networkingv1.Ingress, eventtype, reason, messageFmt string, args ...interface{}) {
+	for i := range ingresses {
+		r.Recorder.Eventf(&ingresses[i], eventtype, reason, messageFmt, args...)
+	}
 }
 
 func (r *IngressReconciler) isIgnored(obj *networkingv1.Ingress) bool {
@@ -470,12 +1036,137 @@ func (r *IngressReconciler) isIgnored(obj *networkingv1.Ingress) bool {
 	return false
 }
 
+func (r *IngressReconciler) isConfigMapWatched(obj client.Object) bool {
+	for _, val := range r.ConfigMapWatchIgnore {
+		if _, exists := obj.GetAnnotations()[val]; exists {
+			return false
+		}
+	}
+
+	return r.configMapSelector().Matches(labels.Set(obj.GetLabels()))
+}
+
+// isIngressWatched tells whether events of an ingress are relevant, result
+// ingresses are always watched so their status can be propagated back.
+func (r *IngressReconciler) isIngressWatched(obj client.Object) bool {
+	if obj.GetAnnotations()[ResultAnnotation] == "true" {
+		return true
+	}
+
+	return r.ingressSelector().Matches(labels.Set(obj.GetLabels()))
+}
+
+func (r *IngressReconciler) ingressSelector() labels.Selector {
+	if r.IngressSelector == nil {
+		return labels.Everything()
+	}
+
+	return r.IngressSelector
+}
+
+func (r *IngressReconciler) configMapSelector() labels.Selector {
+	if r.ConfigMapSelector == nil {
+		return labels.Everything()
+	}
+
+	return r.ConfigMapSelector
+}
+
+// configToGroup maps a merge ConfigMap or IngressMerge referenced by source
+// ingresses to its merge group, so changes on the configuration trigger a new
+// merge.
+func (r *IngressReconciler) configToGroup(obj client.Object) []reconcile.Request {
+	ingresses, err := r.listIngresses(context.Background(), obj.GetNamespace(), r.ingressSelector(), configIndexField, obj.GetName())
+	if err != nil {
+		r.Log.Error(err, "could not list ingresses of configmap",
+			"namespace", obj.GetNamespace(),
+			"configmap", obj.GetName(),
+		)
+		return nil
+	}
+
+	for _, ingress := range ingresses {
+		if ingress.Annotations[ConfigAnnotation] == obj.GetName() {
+			req := mergeGroupRequest(obj.GetNamespace(), obj.GetName())
+			r.settler.observe(req)
+			return []reconcile.Request{req}
+		}
+	}
+
+	return nil
+}
+
 func (r *IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&networkingv1.Ingress{}).
-		Complete(r)
+	if err := r.indexFields(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+
+	if r.DryRun {
+		r.enableDryRun()
+	}
+
+	r.settler = newGroupSettler(r.SettleWindow, r.MaxSettleDelay)
+
+	// requests are merge groups rather than objects, so the builder, which
+	// reconciles the objects of a kind, is not used
+	c, err := controller.New("ingress", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+		RateLimiter:             r.RateLimiter,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(
+		&source.Kind{Type: &networkingv1.Ingress{}},
+		r.ingressGroupHandler(),
+		predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return r.isIngressWatched(e.Object)
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				// an ingress leaving the selector must be removed from its result ingress
+				return r.isIngressWatched(e.ObjectOld) || r.isIngressWatched(e.ObjectNew)
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return r.isIngressWatched(e.Object)
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return r.isIngressWatched(e.Object)
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(
+		&source.Kind{Type: &corev1.ConfigMap{}},
+		handler.EnqueueRequestsFromMapFunc(r.configToGroup),
+		predicate.NewPredicateFuncs(r.isConfigMapWatched),
+		// status annotations written by the controller itself are not relevant
+		predicate.Funcs{UpdateFunc: isConfigMapChanged},
+	)
+	if err != nil {
+		return err
+	}
+
+	if r.EnableIngressMerge {
+		return c.Watch(
+			&source.Kind{Type: &mergev1alpha1.IngressMerge{}},
+			handler.EnqueueRequestsFromMapFunc(r.configToGroup),
+			// status updates made by the controller itself are not relevant
+			predicate.GenerationChangedPredicate{},
+		)
+	}
+
+	return nil
 }
 
+// hasIngressChanged tells whether applying new changes the existing result
+// ingress old. Labels and annotations set by others on old are ignored, the
+// ones applied before but not set by new anymore are changes.
 func (r *IngressReconciler) hasIngressChanged(old, new *networkingv1.Ingress) bool {
 	if new.Namespace != old.Namespace {
 		return true
@@ -483,8 +1174,16 @@ func (r *IngressReconciler) hasIngressChanged(old, new *networkingv1.Ingress) bo
 	if new.Name != old.Name {
 		return true
 	}
-	if !reflect.DeepEqual(new.Labels, old.Labels) {
-		return true
+
+	for k := range new.Labels {
+		if new.Labels[k] != old.Labels[k] {
+			return true
+		}
+	}
+	for k := range appliedKeys(old, "labels") {
+		if _, exists := new.Labels[k]; !exists {
+			return true
+		}
 	}
 
 	for k := range new.Annotations {
@@ -496,6 +1195,11 @@ func (r *IngressReconciler) hasIngressChanged(old, new *networkingv1.Ingress) bo
 			return true
 		}
 	}
+	for k := range appliedKeys(old, "annotations") {
+		if _, exists := new.Annotations[k]; !exists {
+			return true
+		}
+	}
 
 	if !reflect.DeepEqual(new.OwnerReferences, old.OwnerReferences) {
 		return true
@@ -507,6 +1211,31 @@ func (r *IngressReconciler) hasIngressChanged(old, new *networkingv1.Ingress) bo
 	return false
 }
 
+// isConfigMapChanged tells whether an update changes anything but the status
+// annotation of a ConfigMap.
+func isConfigMapChanged(e event.UpdateEvent) bool {
+	withoutStatus := func(obj client.Object) map[string]string {
+		annotations := make(map[string]string)
+		for k, v := range obj.GetAnnotations() {
+			if k != StatusAnnotation {
+				annotations[k] = v
+			}
+		}
+
+		return annotations
+	}
+
+	oldConfigMap, okOld := e.ObjectOld.(*corev1.ConfigMap)
+	newConfigMap, okNew := e.ObjectNew.(*corev1.ConfigMap)
+	if !okOld || !okNew {
+		return true
+	}
+
+	return !reflect.DeepEqual(oldConfigMap.Data, newConfigMap.Data) ||
+		!reflect.DeepEqual(oldConfigMap.Labels, newConfigMap.Labels) ||
+		!reflect.DeepEqual(withoutStatus(oldConfigMap), withoutStatus(newConfigMap))
+}
+
 func getIngressClass(ingress *networkingv1.Ingress) string {
 	ingressClass := ""
 	if ingress.Spec.IngressClassName != nil {
@@ -533,24 +1262,64 @@ func mergeWildcardDomains(wildcardDomains map[string]bool, rules []networkingv1.
 	return wildcardDomains
 }
 
-func wildcardTLSEntry(wildcardDomains map[string]bool, bucket *IngressBucket) networkingv1.IngressTLS {
+func wildcardTLSEntry(wildcardDomains map[string]bool, ingressName string) networkingv1.IngressTLS {
 	hosts := []string{}
 	for domain := range wildcardDomains {
 		hosts = append(hosts, domain)
 	}
 
 	sort.Strings(hosts)
-	secretName := ""
-	if bucket.DestinationIngress != nil {
-		secretName = bucket.DestinationIngress.Name + wildcardTLSSuffix
-	}
 
 	return networkingv1.IngressTLS{
-		SecretName: secretName,
+		SecretName: ingressName + wildcardTLSSuffix,
 		Hosts:      hosts,
 	}
 }
 
+// nextResultIngressName returns the first name in the sequence <base>,
+// <base>-1, <base>-2, ... that is neither used by the result ingresses of
+// the config nor taken by another ingress, so ordinals freed by deleted
+// result ingresses are reused.
+func nextResultIngressName(base string, usedNames map[string]bool, nameTaken NameTaken) (string, error) {
+	name := base
+	for ordinal := 1; ; ordinal++ {
+		if !usedNames[name] {
+			taken, err := nameTaken(name)
+			if err != nil {
+				return "", err
+			}
+
+			if !taken {
+				return name, nil
+			}
+		}
+
+		name = fmt.Sprintf("%s-%d", base, ordinal)
+	}
+}
+
+// withoutIngressOwners returns the owner references that do not reference
+// source ingresses.
+func withoutIngressOwners(ownerReferences []metaV1.OwnerReference) []metaV1.OwnerReference {
+	owners := []metaV1.OwnerReference{}
+	for _, ownerReference := range ownerReferences {
+		if ownerReference.Kind != "Ingress" {
+			owners = append(owners, ownerReference)
+		}
+	}
+
+	return owners
+}
+
+// minRequeueAfter returns the shortest non-zero delay.
+func minRequeueAfter(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+
+	return a
+}
+
 func parseLabels(l string) (labels.Selector, error) {
 	selector, err := labels.Parse(l)
 	if err != nil {
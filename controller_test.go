@@ -2,21 +2,28 @@ package ingress_merge
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	mergev1alpha1 "github.com/tsuru/ingress-merge/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -235,7 +242,7 @@ func TestReconcile(t *testing.T) {
 		_, err := reconciler.Reconcile(ctx, reconcile.Request{
 			NamespacedName: types.NamespacedName{
 				Namespace: "my-namespace",
-				Name:      "my-instance",
+				Name:      "kubernetes-shared-ingress",
 			},
 		})
 
@@ -253,7 +260,7 @@ func TestReconcile(t *testing.T) {
 		_, err := reconciler.Reconcile(ctx, reconcile.Request{
 			NamespacedName: types.NamespacedName{
 				Namespace: "my-namespace",
-				Name:      "my-instance",
+				Name:      "kubernetes-shared-ingress",
 			},
 		})
 
@@ -300,7 +307,7 @@ func TestReconcile(t *testing.T) {
 		_, err := reconciler.Reconcile(ctx, reconcile.Request{
 			NamespacedName: types.NamespacedName{
 				Namespace: "my-namespace",
-				Name:      "my-instance",
+				Name:      "kubernetes-shared-ingress",
 			},
 		})
 
@@ -374,7 +381,7 @@ func TestReconcile(t *testing.T) {
 		_, err := reconciler.Reconcile(ctx, reconcile.Request{
 			NamespacedName: types.NamespacedName{
 				Namespace: "my-namespace",
-				Name:      "my-instance",
+				Name:      "kubernetes-shared-ingress",
 			},
 		})
 
@@ -453,6 +460,27 @@ func TestReconcile(t *testing.T) {
 		}, sharedIngress.Spec)
 	})
 
+	t.Run("name configured on config map", func(t *testing.T) {
+		configMap := configMap1.DeepCopy()
+		configMap.Data[NameConfigKey] = "kubernetes-shared-ingress-named"
+		reconciler := newTestReconciler([]runtime.Object{
+			instance1, configMap,
+		})
+
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: "my-namespace",
+				Name:      "kubernetes-shared-ingress",
+			},
+		})
+		require.NoError(t, err)
+
+		sharedIngresses, err := getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+		require.NoError(t, err)
+		require.Len(t, sharedIngresses, 1)
+		assert.Equal(t, "kubernetes-shared-ingress-named", sharedIngresses[0].Name)
+	})
+
 	t.Run("multiple instances and one ingress, found shared ingress", func(t *testing.T) {
 		sharedIngress := &networkingv1.Ingress{
 			ObjectMeta: metaV1.ObjectMeta{
@@ -481,7 +509,7 @@ func TestReconcile(t *testing.T) {
 		_, err := reconciler.Reconcile(ctx, reconcile.Request{
 			NamespacedName: types.NamespacedName{
 				Namespace: "my-namespace",
-				Name:      "my-instance",
+				Name:      "kubernetes-shared-ingress",
 			},
 		})
 
@@ -556,7 +584,7 @@ func TestReconcile(t *testing.T) {
 		_, err := reconciler.Reconcile(ctx, reconcile.Request{
 			NamespacedName: types.NamespacedName{
 				Namespace: "my-namespace",
-				Name:      "my-instance-0",
+				Name:      "kubernetes-shared-ingress",
 			},
 		})
 
@@ -566,6 +594,10 @@ func TestReconcile(t *testing.T) {
 		require.NoError(t, err)
 		require.Len(t, sharedIngresses, 3)
 
+		assert.Equal(t, "kubernetes-shared-ingress", sharedIngresses[0].Name)
+		assert.Equal(t, "kubernetes-shared-ingress-1", sharedIngresses[1].Name)
+		assert.Equal(t, "kubernetes-shared-ingress-2", sharedIngresses[2].Name)
+
 		assert.Equal(t, 100, (len(sharedIngresses[0].Spec.Rules) +
 			len(sharedIngresses[1].Spec.Rules) +
 			len(sharedIngresses[2].Spec.Rules)))
@@ -573,7 +605,7 @@ func TestReconcile(t *testing.T) {
 		_, err = reconciler.Reconcile(ctx, reconcile.Request{
 			NamespacedName: types.NamespacedName{
 				Namespace: "my-namespace",
-				Name:      "my-instance-1",
+				Name:      "kubernetes-shared-ingress",
 			},
 		})
 		require.NoError(t, err)
@@ -592,7 +624,7 @@ func TestReconcile(t *testing.T) {
 		_, err = reconciler.Reconcile(ctx, reconcile.Request{
 			NamespacedName: types.NamespacedName{
 				Namespace: "my-namespace",
-				Name:      "my-instance-3",
+				Name:      "kubernetes-shared-ingress",
 			},
 		})
 		require.NoError(t, err)
@@ -615,7 +647,7 @@ func TestReconcile(t *testing.T) {
 		_, err = reconciler.Reconcile(ctx, reconcile.Request{
 			NamespacedName: types.NamespacedName{
 				Namespace: "my-namespace",
-				Name:      "my-instance-4",
+				Name:      "kubernetes-shared-ingress",
 			},
 		})
 		require.NoError(t, err)
@@ -634,6 +666,1211 @@ func TestReconcile(t *testing.T) {
 	})
 }
 
+func TestConfigToGroup(t *testing.T) {
+	objects := []runtime.Object{}
+	for i, configMapName := range []string{"shared-ingress", "shared-ingress", "other-ingress"} {
+		objects = append(objects, &networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      fmt.Sprintf("my-instance-%d", i),
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+					ConfigAnnotation:       configMapName,
+				},
+			},
+		})
+	}
+	objects = append(objects, &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "other-namespace",
+			Name:      "my-instance",
+			Annotations: map[string]string{
+				IngressClassAnnotation: "merge",
+				ConfigAnnotation:       "shared-ingress",
+			},
+		},
+	})
+
+	reconciler := newTestReconciler(objects)
+	requests := reconciler.configToGroup(&corev1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      "shared-ingress",
+		},
+	})
+
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: "my-namespace", Name: "shared-ingress"}},
+	}, requests)
+
+	requests = reconciler.configToGroup(&corev1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      "unreferenced",
+		},
+	})
+	assert.Empty(t, requests)
+}
+
+func TestIsConfigMapWatched(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      "shared-ingress",
+			Labels: map[string]string{
+				"merge.ingress.kubernetes.io": "owned",
+			},
+		},
+	}
+	leaderConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      "leader",
+			Annotations: map[string]string{
+				"control-plane.alpha.kubernetes.io/leader": "{}",
+			},
+		},
+	}
+
+	reconciler := newTestReconciler(nil)
+	assert.True(t, reconciler.isConfigMapWatched(configMap))
+	assert.True(t, reconciler.isConfigMapWatched(leaderConfigMap))
+
+	reconciler.ConfigMapWatchIgnore = []string{"control-plane.alpha.kubernetes.io/leader"}
+	assert.True(t, reconciler.isConfigMapWatched(configMap))
+	assert.False(t, reconciler.isConfigMapWatched(leaderConfigMap))
+
+	reconciler.ConfigMapSelector, _ = labels.Parse("merge.ingress.kubernetes.io=owned")
+	assert.True(t, reconciler.isConfigMapWatched(configMap))
+
+	reconciler.ConfigMapSelector, _ = labels.Parse("merge.ingress.kubernetes.io=other")
+	assert.False(t, reconciler.isConfigMapWatched(configMap))
+}
+
+func TestReconcileIngressSelector(t *testing.T) {
+	ctx := context.Background()
+
+	objects := []runtime.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      "kubernetes-shared-ingress",
+				Labels: map[string]string{
+					"shard": "a",
+				},
+			},
+			Data: map[string]string{
+				"ingressClassName": "my-next-ingress",
+			},
+		},
+	}
+	for i, shard := range []string{"a", "a", "b"} {
+		objects = append(objects, &networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      fmt.Sprintf("my-instance-%d", i),
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+					ConfigAnnotation:       "kubernetes-shared-ingress",
+				},
+				Labels: map[string]string{
+					"shard": shard,
+				},
+			},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					{
+						Host: fmt.Sprintf("instance%d.example.org", i),
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	t.Run("only selected ingresses are merged", func(t *testing.T) {
+		reconciler := newTestReconciler(objects)
+		reconciler.IngressSelector, _ = labels.Parse("shard=a")
+
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: "my-namespace",
+				Name:      "kubernetes-shared-ingress",
+			},
+		})
+		require.NoError(t, err)
+
+		sharedIngresses, err := getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+		require.NoError(t, err)
+		require.Len(t, sharedIngresses, 1)
+		require.Len(t, sharedIngresses[0].Spec.Rules, 2)
+		assert.Equal(t, "instance0.example.org", sharedIngresses[0].Spec.Rules[0].Host)
+		assert.Equal(t, "instance1.example.org", sharedIngresses[0].Spec.Rules[1].Host)
+	})
+
+	t.Run("configmap not matching selector is skipped", func(t *testing.T) {
+		reconciler := newTestReconciler(objects)
+		reconciler.ConfigMapSelector, _ = labels.Parse("shard=b")
+
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: "my-namespace",
+				Name:      "kubernetes-shared-ingress",
+			},
+		})
+		require.NoError(t, err)
+
+		sharedIngresses, err := getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+		require.NoError(t, err)
+		assert.Len(t, sharedIngresses, 0)
+	})
+}
+
+func TestGetMergeConfigNamespaceLabels(t *testing.T) {
+	ctx := context.Background()
+
+	labelAffinity := map[string]string{
+		PackingStrategyConfigKey: LabelAffinityPackingName,
+		PackingLabelConfigKey:    "team",
+	}
+	reconciler := newTestReconciler([]runtime.Object{
+		&corev1.Namespace{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:   "my-namespace",
+				Labels: map[string]string{"team": "red"},
+			},
+		},
+		newTestConfigMap("label-affinity", labelAffinity),
+		newTestConfigMap("first-fit", nil),
+	})
+
+	config, err := reconciler.getMergeConfig(ctx, "my-namespace", "label-affinity")
+	require.NoError(t, err)
+	assert.Equal(t, LabelAffinityPacking{
+		Label: "team",
+		NamespaceLabels: map[string]map[string]string{
+			"my-namespace": {"team": "red"},
+		},
+	}, config.PackingStrategy)
+
+	config, err = reconciler.getMergeConfig(ctx, "my-namespace", "first-fit")
+	require.NoError(t, err)
+	assert.Equal(t, FirstFitPacking{}, config.PackingStrategy)
+
+	// a namespace being deleted has no labels left
+	reconciler = newTestReconciler([]runtime.Object{
+		newTestConfigMap("label-affinity", labelAffinity),
+	})
+	config, err = reconciler.getMergeConfig(ctx, "my-namespace", "label-affinity")
+	require.NoError(t, err)
+	assert.Equal(t, "team", config.PackingStrategy.(LabelAffinityPacking).Label)
+}
+
+func TestReconcileGroup(t *testing.T) {
+	ctx := context.Background()
+
+	reconcileGroup := func(t *testing.T, reconciler *IngressReconciler, configName string) {
+		_, err := reconciler.Reconcile(ctx, mergeGroupRequest("my-namespace", configName))
+		require.NoError(t, err)
+	}
+	resultHosts := func(t *testing.T, reconciler *IngressReconciler) map[string][]string {
+		sharedIngresses, err := getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+		require.NoError(t, err)
+
+		hosts := make(map[string][]string)
+		for _, sharedIngress := range sharedIngresses {
+			for _, rule := range sharedIngress.Spec.Rules {
+				hosts[sharedIngress.Name] = append(hosts[sharedIngress.Name], rule.Host)
+			}
+			sort.Strings(hosts[sharedIngress.Name])
+		}
+		return hosts
+	}
+
+	reconciler := newTestReconciler([]runtime.Object{
+		newTestSource("a", "kubernetes-shared-ingress-a"),
+		newTestSource("b", "kubernetes-shared-ingress-b"),
+		newTestConfigMap("kubernetes-shared-ingress-a", nil),
+		newTestConfigMap("kubernetes-shared-ingress-b", nil),
+	})
+
+	// only the reconciled group is merged
+	reconcileGroup(t, reconciler, "kubernetes-shared-ingress-a")
+	assert.Equal(t, map[string][]string{
+		"kubernetes-shared-ingress-a": {"a.example.org"},
+	}, resultHosts(t, reconciler))
+
+	reconcileGroup(t, reconciler, "kubernetes-shared-ingress-b")
+	assert.Equal(t, map[string][]string{
+		"kubernetes-shared-ingress-a": {"a.example.org"},
+		"kubernetes-shared-ingress-b": {"b.example.org"},
+	}, resultHosts(t, reconciler))
+
+	// an ingress moving to another config enqueues both groups
+	moved := &networkingv1.Ingress{}
+	require.NoError(t, reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "my-namespace", Name: "b"}, moved))
+	moved.Annotations[ConfigAnnotation] = "kubernetes-shared-ingress-a"
+	require.NoError(t, reconciler.Client.Update(ctx, moved))
+
+	reconcileGroup(t, reconciler, "kubernetes-shared-ingress-a")
+	reconcileGroup(t, reconciler, "kubernetes-shared-ingress-b")
+	assert.Equal(t, map[string][]string{
+		"kubernetes-shared-ingress-a": {"a.example.org", "b.example.org"},
+	}, resultHosts(t, reconciler))
+
+	require.NoError(t, reconciler.Client.Delete(ctx, moved))
+
+	reconcileGroup(t, reconciler, "kubernetes-shared-ingress-a")
+	assert.Equal(t, map[string][]string{
+		"kubernetes-shared-ingress-a": {"a.example.org"},
+	}, resultHosts(t, reconciler))
+}
+
+func TestNextResultIngressName(t *testing.T) {
+	nextName := func(usedNames, takenNames map[string]bool) string {
+		name, err := nextResultIngressName("shared", usedNames, TakenNames(takenNames))
+		require.NoError(t, err)
+		return name
+	}
+
+	assert.Equal(t, "shared", nextName(map[string]bool{}, nil))
+	assert.Equal(t, "shared-1", nextName(map[string]bool{"shared": true}, nil))
+	assert.Equal(t, "shared-1", nextName(map[string]bool{"shared": true, "shared-2": true}, nil))
+	assert.Equal(t, "shared", nextName(map[string]bool{"shared-1": true}, nil))
+	assert.Equal(t, "shared-2", nextName(map[string]bool{"shared": true}, map[string]bool{"shared-1": true}))
+
+	// names are not looked up once used by a result ingress
+	looked := []string{}
+	name, err := nextResultIngressName("shared", map[string]bool{"shared": true}, func(name string) (bool, error) {
+		looked = append(looked, name)
+		return false, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "shared-1", name)
+	assert.Equal(t, []string{"shared-1"}, looked)
+}
+
+func TestReconcileSkipsTakenNames(t *testing.T) {
+	ctx := context.Background()
+
+	// a source named like its config
+	reconciler := newTestReconciler([]runtime.Object{
+		newTestSource("kubernetes-shared-ingress", "kubernetes-shared-ingress"),
+		newTestConfigMap("kubernetes-shared-ingress", nil),
+	})
+
+	_, err := reconciler.Reconcile(ctx, mergeGroupRequest("my-namespace", "kubernetes-shared-ingress"))
+	require.NoError(t, err)
+
+	result := &networkingv1.Ingress{}
+	require.NoError(t, reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "my-namespace", Name: "kubernetes-shared-ingress-1"}, result))
+	assert.Equal(t, "true", result.Annotations[ResultAnnotation])
+}
+
+func TestReconcileEvents(t *testing.T) {
+	ctx := context.Background()
+
+	invalidPriority := withAnnotations(*newTestSource("invalid-priority", "kubernetes-shared-ingress"), map[string]string{
+		PriorityAnnotation: "high",
+	})
+	missingConfig := newTestSource("missing-config", "")
+	delete(missingConfig.Annotations, ConfigAnnotation)
+
+	reconciler := newTestReconciler([]runtime.Object{
+		&invalidPriority,
+		missingConfig,
+		newTestSource("unknown-config", "unknown"),
+		newTestSource("bad-config", "kubernetes-shared-ingress"),
+		newTestConfigMap("kubernetes-shared-ingress", map[string]string{
+			LabelsConfigKey: "{invalid",
+		}),
+	})
+
+	// the request without name reports the ingresses without config
+	for _, configName := range []string{"kubernetes-shared-ingress", "unknown", ""} {
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: "my-namespace",
+				Name:      configName,
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	recorder := reconciler.Recorder.(*record.FakeRecorder)
+	close(recorder.Events)
+	events := []string{}
+	for event := range recorder.Events {
+		parts := strings.SplitN(event, " ", 3)
+		events = append(events, parts[0]+" "+parts[1])
+	}
+
+	assert.ElementsMatch(t, []string{
+		"Warning " + InvalidPriorityReason,
+		"Warning " + MissingConfigReason,
+		"Warning " + ConfigMapNotFoundReason,
+		"Warning " + InvalidConfigReason,
+		"Normal " + CreatedReason,
+	}, events)
+}
+
+func TestReconcileEmptyResultIngresses(t *testing.T) {
+	ctx := context.Background()
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: "my-namespace",
+			Name:      "kubernetes-shared-ingress",
+		},
+	}
+
+	t.Run("result of a config without sources is deleted", func(t *testing.T) {
+		reconciler := newTestReconciler([]runtime.Object{
+			newTestSource("my-instance", "other-shared-ingress"),
+			newTestConfigMap("kubernetes-shared-ingress", nil),
+			newTestConfigMap("other-shared-ingress", nil),
+			newTestResult("kubernetes-shared-ingress", "kubernetes-shared-ingress", "my-instance"),
+		})
+
+		result, err := reconciler.Reconcile(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, result)
+
+		_, err = reconciler.Reconcile(ctx, mergeGroupRequest("my-namespace", "other-shared-ingress"))
+		require.NoError(t, err)
+
+		err = reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "my-namespace", Name: "kubernetes-shared-ingress"}, &networkingv1.Ingress{})
+		assert.True(t, k8sErrors.IsNotFound(err))
+
+		err = reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "my-namespace", Name: "other-shared-ingress"}, &networkingv1.Ingress{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("empty bucket is deleted", func(t *testing.T) {
+		reconciler := newTestReconciler([]runtime.Object{
+			newTestSource("my-instance", "kubernetes-shared-ingress"),
+			newTestConfigMap("kubernetes-shared-ingress", nil),
+			newTestResult("kubernetes-shared-ingress", "kubernetes-shared-ingress", "my-instance"),
+			newTestResult("kubernetes-shared-ingress-1", "kubernetes-shared-ingress", "deleted-instance"),
+		})
+
+		_, err := reconciler.Reconcile(ctx, request)
+		require.NoError(t, err)
+
+		sharedIngresses, err := getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+		require.NoError(t, err)
+		require.Len(t, sharedIngresses, 1)
+		assert.Equal(t, "kubernetes-shared-ingress", sharedIngresses[0].Name)
+	})
+
+	t.Run("empty result is kept during the grace period", func(t *testing.T) {
+		reconciler := newTestReconciler([]runtime.Object{
+			newTestSource("my-instance", "kubernetes-shared-ingress"),
+			newTestConfigMap("kubernetes-shared-ingress", nil),
+			newTestResult("kubernetes-shared-ingress", "kubernetes-shared-ingress", "my-instance"),
+			newTestResult("kubernetes-shared-ingress-1", "kubernetes-shared-ingress", "deleted-instance"),
+		})
+		reconciler.ResultIngressGracePeriod = time.Hour
+
+		result, err := reconciler.Reconcile(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, time.Hour, result.RequeueAfter)
+
+		var emptyIngress networkingv1.Ingress
+		err = reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "my-namespace", Name: "kubernetes-shared-ingress-1"}, &emptyIngress)
+		require.NoError(t, err)
+		require.Contains(t, emptyIngress.Annotations, EmptySinceAnnotation)
+
+		emptyIngress.Annotations[EmptySinceAnnotation] = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+		err = reconciler.Client.Update(ctx, &emptyIngress)
+		require.NoError(t, err)
+
+		result, err = reconciler.Reconcile(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, result)
+
+		err = reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "my-namespace", Name: "kubernetes-shared-ingress-1"}, &emptyIngress)
+		assert.True(t, k8sErrors.IsNotFound(err))
+	})
+
+	t.Run("result outlives its last source during the grace period", func(t *testing.T) {
+		reconciler := newTestReconciler([]runtime.Object{
+			newTestSource("my-instance", "kubernetes-shared-ingress"),
+			newTestConfigMap("kubernetes-shared-ingress", nil),
+		})
+		reconciler.ResultIngressGracePeriod = time.Hour
+		key := client.ObjectKey{Namespace: "my-namespace", Name: "kubernetes-shared-ingress"}
+
+		_, err := reconciler.Reconcile(ctx, request)
+		require.NoError(t, err)
+
+		require.NoError(t, reconciler.Client.Delete(ctx, newTestSource("my-instance", "kubernetes-shared-ingress")))
+		collectGarbage(ctx, t, reconciler.Client, "my-namespace")
+
+		result, err := reconciler.Reconcile(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, time.Hour, result.RequeueAfter)
+
+		var emptyIngress networkingv1.Ingress
+		require.NoError(t, reconciler.Client.Get(ctx, key, &emptyIngress))
+		require.Contains(t, emptyIngress.Annotations, EmptySinceAnnotation)
+		require.Len(t, emptyIngress.OwnerReferences, 1)
+		assert.Equal(t, "ConfigMap", emptyIngress.OwnerReferences[0].Kind)
+
+		collectGarbage(ctx, t, reconciler.Client, "my-namespace")
+		require.NoError(t, reconciler.Client.Get(ctx, key, &emptyIngress))
+
+		emptyIngress.Annotations[EmptySinceAnnotation] = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+		require.NoError(t, reconciler.Client.Update(ctx, &emptyIngress))
+
+		_, err = reconciler.Reconcile(ctx, request)
+		require.NoError(t, err)
+
+		err = reconciler.Client.Get(ctx, key, &emptyIngress)
+		assert.True(t, k8sErrors.IsNotFound(err))
+	})
+
+	t.Run("result outlives its config without a grace period", func(t *testing.T) {
+		configMap := newTestConfigMap("kubernetes-shared-ingress", nil)
+		reconciler := newTestReconciler([]runtime.Object{
+			newTestSource("my-instance", "kubernetes-shared-ingress"),
+			configMap,
+		})
+		key := client.ObjectKey{Namespace: "my-namespace", Name: "kubernetes-shared-ingress"}
+
+		_, err := reconciler.Reconcile(ctx, request)
+		require.NoError(t, err)
+
+		var resultIngress networkingv1.Ingress
+		require.NoError(t, reconciler.Client.Get(ctx, key, &resultIngress))
+		require.Len(t, resultIngress.OwnerReferences, 1)
+		assert.Equal(t, "Ingress", resultIngress.OwnerReferences[0].Kind)
+
+		require.NoError(t, reconciler.Client.Delete(ctx, configMap))
+		collectGarbage(ctx, t, reconciler.Client, "my-namespace")
+		assert.NoError(t, reconciler.Client.Get(ctx, key, &resultIngress))
+	})
+}
+
+func TestReconcileIngressMerge(t *testing.T) {
+	ctx := context.Background()
+
+	ingressClassName := "my-next-ingress"
+	maxSlots := int32(1)
+	ingressMerge := &mergev1alpha1.IngressMerge{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace:  "my-namespace",
+			Name:       "kubernetes-shared-ingress",
+			Generation: 2,
+		},
+		Spec: mergev1alpha1.IngressMergeSpec{
+			Name: "kubernetes-shared-ingress-crd",
+			Labels: map[string]string{
+				"ingress-merge-label": "label01",
+			},
+			Annotations: map[string]string{
+				"ingress-merge-annotation": "annotation01",
+			},
+			IngressClassName: &ingressClassName,
+			MaxSlots:         &maxSlots,
+			IngressSelector: &metaV1.LabelSelector{
+				MatchLabels: map[string]string{
+					"merge": "true",
+				},
+			},
+		},
+	}
+	// a configmap with the same name is shadowed by the IngressMerge
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      "kubernetes-shared-ingress",
+		},
+		Data: map[string]string{
+			NameConfigKey: "kubernetes-shared-ingress-configmap",
+		},
+	}
+
+	objects := []runtime.Object{ingressMerge, configMap}
+	for i, merge := range []string{"true", "true", "false"} {
+		objects = append(objects, &networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      fmt.Sprintf("my-instance-%d", i),
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+					ConfigAnnotation:       "kubernetes-shared-ingress",
+				},
+				Labels: map[string]string{
+					"merge": merge,
+				},
+			},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					{
+						Host: fmt.Sprintf("instance%d.example.org", i),
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	reconciler := newTestReconciler(objects)
+	_, err := reconciler.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: "my-namespace",
+			Name:      "kubernetes-shared-ingress",
+		},
+	})
+	require.NoError(t, err)
+
+	sharedIngresses, err := getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+	require.NoError(t, err)
+	require.Len(t, sharedIngresses, 2)
+
+	for i, sharedIngress := range sharedIngresses {
+		assert.Equal(t, []string{"kubernetes-shared-ingress-crd", "kubernetes-shared-ingress-crd-1"}[i], sharedIngress.Name)
+		assert.Equal(t, map[string]string{
+			"ingress-merge-annotation": "annotation01",
+			ResultAnnotation:           "true",
+			FromConfigAnnotation:       "kubernetes-shared-ingress",
+		}, sharedIngress.Annotations)
+		assert.Equal(t, map[string]string{
+			"ingress-merge-label": "label01",
+		}, sharedIngress.Labels)
+		assert.Equal(t, &ingressClassName, sharedIngress.Spec.IngressClassName)
+		require.Len(t, sharedIngress.Spec.Rules, 1)
+		assert.NotEqual(t, "instance2.example.org", sharedIngress.Spec.Rules[0].Host)
+	}
+
+	err = reconciler.Client.Get(ctx, client.ObjectKeyFromObject(ingressMerge), ingressMerge)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), ingressMerge.Status.ObservedGeneration)
+	assert.Equal(t, []string{"kubernetes-shared-ingress-crd", "kubernetes-shared-ingress-crd-1"}, ingressMerge.Status.ResultIngresses)
+	require.Len(t, ingressMerge.Status.Conditions, 1)
+	assert.Equal(t, mergev1alpha1.ReadyCondition, ingressMerge.Status.Conditions[0].Type)
+	assert.Equal(t, metaV1.ConditionTrue, ingressMerge.Status.Conditions[0].Status)
+	require.Len(t, ingressMerge.Status.Buckets, 2)
+	assert.Equal(t, "kubernetes-shared-ingress-crd", ingressMerge.Status.Buckets[0].Name)
+	assert.Len(t, ingressMerge.Status.Buckets[0].Sources, 1)
+	assert.Equal(t, int32(0), ingressMerge.Status.Buckets[0].FreeSlots)
+	assert.Equal(t, []mergev1alpha1.SkippedIngress{
+		{Name: "my-instance-2", Reason: IngressSelectorReason},
+	}, ingressMerge.Status.SkippedIngresses)
+	assert.NotNil(t, ingressMerge.Status.LastReconcileTime)
+	assert.Empty(t, ingressMerge.Status.LastError)
+}
+
+func TestReconcileConfigMapMaxSlots(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		maxSlots        string
+		sharedIngresses int
+		events          []string
+	}{
+		{"1", 2, []string{"Normal " + CreatedReason, "Normal " + CreatedReason}},
+		{"0", 1, []string{"Warning " + InvalidConfigReason, "Warning " + InvalidConfigReason, "Normal " + CreatedReason}},
+		{"many", 1, []string{"Warning " + InvalidConfigReason, "Warning " + InvalidConfigReason, "Normal " + CreatedReason}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.maxSlots, func(t *testing.T) {
+			objects := []runtime.Object{
+				&corev1.ConfigMap{
+					ObjectMeta: metaV1.ObjectMeta{
+						Namespace: "my-namespace",
+						Name:      "kubernetes-shared-ingress",
+					},
+					Data: map[string]string{
+						MaxSlotsConfigKey: tt.maxSlots,
+					},
+				},
+			}
+			for i := 0; i < 2; i++ {
+				objects = append(objects, &networkingv1.Ingress{
+					ObjectMeta: metaV1.ObjectMeta{
+						Namespace: "my-namespace",
+						Name:      fmt.Sprintf("my-instance-%d", i),
+						Annotations: map[string]string{
+							IngressClassAnnotation: "merge",
+							ConfigAnnotation:       "kubernetes-shared-ingress",
+						},
+					},
+					Spec: networkingv1.IngressSpec{
+						Rules: []networkingv1.IngressRule{
+							{
+								Host: fmt.Sprintf("instance%d.example.org", i),
+								IngressRuleValue: networkingv1.IngressRuleValue{
+									HTTP: &networkingv1.HTTPIngressRuleValue{},
+								},
+							},
+						},
+					},
+				})
+			}
+
+			reconciler := newTestReconciler(objects)
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: "my-namespace",
+					Name:      "kubernetes-shared-ingress",
+				},
+			})
+			require.NoError(t, err)
+
+			sharedIngresses, err := getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+			require.NoError(t, err)
+			assert.Len(t, sharedIngresses, tt.sharedIngresses)
+
+			recorder := reconciler.Recorder.(*record.FakeRecorder)
+			close(recorder.Events)
+			events := []string{}
+			for event := range recorder.Events {
+				parts := strings.SplitN(event, " ", 3)
+				events = append(events, parts[0]+" "+parts[1])
+			}
+			assert.ElementsMatch(t, tt.events, events)
+		})
+	}
+}
+
+func TestReconcileConfigMapStatus(t *testing.T) {
+	ctx := context.Background()
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      "kubernetes-shared-ingress",
+		},
+		Data: map[string]string{
+			MaxSlotsConfigKey: "many",
+		},
+	}
+
+	objects := []runtime.Object{configMap}
+	for i, priority := range []string{"1", "2", "high"} {
+		objects = append(objects, &networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      fmt.Sprintf("my-instance-%d", i),
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+					ConfigAnnotation:       "kubernetes-shared-ingress",
+					PriorityAnnotation:     priority,
+				},
+			},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					{
+						Host: fmt.Sprintf("instance%d.example.org", i),
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	reconciler := newTestReconciler(objects)
+	_, err := reconciler.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: "my-namespace",
+			Name:      "kubernetes-shared-ingress",
+		},
+	})
+	require.NoError(t, err)
+
+	err = reconciler.Client.Get(ctx, client.ObjectKeyFromObject(configMap), configMap)
+	require.NoError(t, err)
+
+	var status mergev1alpha1.IngressMergeStatus
+	require.NoError(t, json.Unmarshal([]byte(configMap.Annotations[StatusAnnotation]), &status))
+
+	assert.Equal(t, []string{"kubernetes-shared-ingress"}, status.ResultIngresses)
+	assert.Equal(t, []mergev1alpha1.ResultIngressStatus{
+		{
+			Name:      "kubernetes-shared-ingress",
+			Sources:   []string{"my-instance-0", "my-instance-1"},
+			FreeSlots: 43,
+		},
+	}, status.Buckets)
+	assert.Equal(t, []mergev1alpha1.SkippedIngress{
+		{Name: "my-instance-2", Reason: InvalidPriorityReason},
+	}, status.SkippedIngresses)
+	assert.NotNil(t, status.LastReconcileTime)
+	assert.Contains(t, status.LastError, MaxSlotsConfigKey)
+	assert.Empty(t, status.Conditions)
+
+	// merging again leaves the status alone
+	resourceVersion := configMap.ResourceVersion
+	_, err = reconciler.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: "my-namespace",
+			Name:      "kubernetes-shared-ingress",
+		},
+	})
+	require.NoError(t, err)
+
+	err = reconciler.Client.Get(ctx, client.ObjectKeyFromObject(configMap), configMap)
+	require.NoError(t, err)
+	assert.Equal(t, resourceVersion, configMap.ResourceVersion)
+}
+
+func TestReconcilePathConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	source := func(name string, created time.Time) *networkingv1.Ingress {
+		ingress := createdAt(withAnnotations(newTestIngress(name, "example.org", "/", "/"+name), map[string]string{
+			IngressClassAnnotation: "merge",
+			ConfigAnnotation:       "kubernetes-shared-ingress",
+		}), created)
+		return &ingress
+	}
+
+	reconciler := newTestReconciler([]runtime.Object{
+		newTestConfigMap("kubernetes-shared-ingress", nil),
+		source("older-instance", time.Now().Add(-time.Hour)),
+		source("newer-instance", time.Now()),
+	})
+	recorder := record.NewFakeRecorder(1000)
+	reconciler.Recorder = objectRecorder{recorder}
+
+	_, err := reconciler.Reconcile(ctx, mergeGroupRequest("my-namespace", "kubernetes-shared-ingress"))
+	require.NoError(t, err)
+
+	close(recorder.Events)
+	events := []string{}
+	for event := range recorder.Events {
+		if strings.HasPrefix(event, corev1.EventTypeWarning+" "+PathConflictReason+" ") {
+			events = append(events, event)
+		}
+	}
+	// the path is kept by the oldest ingress
+	require.Len(t, events, 1)
+	assert.True(t, strings.HasPrefix(events[0], corev1.EventTypeWarning+" "+PathConflictReason+" newer-instance: "), events[0])
+	assert.Contains(t, events[0], "declared by ingress older-instance")
+
+	configMap := &corev1.ConfigMap{}
+	err = reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "my-namespace", Name: "kubernetes-shared-ingress"}, configMap)
+	require.NoError(t, err)
+
+	var status mergev1alpha1.IngressMergeStatus
+	require.NoError(t, json.Unmarshal([]byte(configMap.Annotations[StatusAnnotation]), &status))
+	assert.Equal(t, []mergev1alpha1.PathConflictStatus{
+		{
+			Name:     "newer-instance",
+			Winner:   "older-instance",
+			Host:     "example.org",
+			Path:     "/",
+			PathType: networkingv1.PathTypeImplementationSpecific,
+		},
+	}, status.PathConflicts)
+}
+
+// objectRecorder prefixes the messages of the events with the name of their
+// object, which the fake recorder leaves out.
+type objectRecorder struct {
+	*record.FakeRecorder
+}
+
+func (r objectRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	name := object.(metaV1.Object).GetName()
+	r.FakeRecorder.Eventf(object, eventtype, reason, "%s: "+messageFmt, append([]interface{}{name}, args...)...)
+}
+
+func TestIsConfigMapChanged(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      "kubernetes-shared-ingress",
+		},
+		Data: map[string]string{
+			NameConfigKey: "shared",
+		},
+	}
+
+	withStatus := configMap.DeepCopy()
+	withStatus.Annotations = map[string]string{StatusAnnotation: "{}"}
+	assert.False(t, isConfigMapChanged(event.UpdateEvent{ObjectOld: configMap, ObjectNew: withStatus}))
+
+	withData := withStatus.DeepCopy()
+	withData.Data[NameConfigKey] = "other"
+	assert.True(t, isConfigMapChanged(event.UpdateEvent{ObjectOld: withStatus, ObjectNew: withData}))
+
+	withAnnotation := withStatus.DeepCopy()
+	withAnnotation.Annotations["ignore"] = "true"
+	assert.True(t, isConfigMapChanged(event.UpdateEvent{ObjectOld: withStatus, ObjectNew: withAnnotation}))
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	ctx := context.Background()
+
+	reconciler := newTestReconciler([]runtime.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      "kubernetes-shared-ingress",
+			},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      "my-instance",
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+					ConfigAnnotation:       "kubernetes-shared-ingress",
+				},
+			},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					{
+						Host: "instance.example.org",
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{},
+						},
+					},
+				},
+			},
+		},
+	})
+	reconciler.DryRun = true
+	reconciler.enableDryRun()
+
+	_, err := reconciler.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: "my-namespace",
+			Name:      "kubernetes-shared-ingress",
+		},
+	})
+	require.NoError(t, err)
+
+	sharedIngresses, err := getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+	require.NoError(t, err)
+	assert.Empty(t, sharedIngresses)
+
+	recorder := reconciler.Recorder.(*dryRunRecorder).EventRecorder.(*record.FakeRecorder)
+	close(recorder.Events)
+	events := []string{}
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	assert.Equal(t, []string{
+		"Normal " + CreatedReason + " dry run: merged 1 ingresses from configmap kubernetes-shared-ingress",
+	}, events)
+}
+
+func TestReconcileSettle(t *testing.T) {
+	ctx := context.Background()
+
+	reconciler := newTestReconciler([]runtime.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      "kubernetes-shared-ingress",
+			},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      "my-instance",
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+					ConfigAnnotation:       "kubernetes-shared-ingress",
+				},
+			},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					{
+						Host: "instance.example.org",
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	now := time.Now()
+	reconciler.settler = newGroupSettler(10*time.Second, time.Minute)
+	reconciler.settler.now = func() time.Time { return now }
+
+	request := mergeGroupRequest("my-namespace", "kubernetes-shared-ingress")
+	reconciler.settler.observe(request)
+
+	result, err := reconciler.Reconcile(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, result.RequeueAfter)
+
+	sharedIngresses, err := getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+	require.NoError(t, err)
+	assert.Empty(t, sharedIngresses)
+
+	now = now.Add(10 * time.Second)
+	result, err = reconciler.Reconcile(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+
+	sharedIngresses, err = getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+	require.NoError(t, err)
+	assert.Len(t, sharedIngresses, 1)
+}
+
+func TestReconcileBucketCompaction(t *testing.T) {
+	ctx := context.Background()
+
+	objects := []runtime.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      "kubernetes-shared-ingress",
+			},
+		},
+	}
+	for i, resultName := range []string{"kubernetes-shared-ingress", "kubernetes-shared-ingress-1"} {
+		sourceName := fmt.Sprintf("my-instance-%d", i)
+		objects = append(objects, &networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      sourceName,
+				UID:       types.UID(sourceName),
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+					ConfigAnnotation:       "kubernetes-shared-ingress",
+				},
+			},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					{
+						Host: sourceName + ".example.org",
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{},
+						},
+					},
+				},
+			},
+		}, &networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "my-namespace",
+				Name:      resultName,
+				UID:       types.UID(resultName),
+				Annotations: map[string]string{
+					ResultAnnotation:     "true",
+					FromConfigAnnotation: "kubernetes-shared-ingress",
+				},
+				OwnerReferences: []metaV1.OwnerReference{
+					{
+						Kind: "Ingress",
+						Name: sourceName,
+						UID:  types.UID(sourceName),
+					},
+				},
+			},
+		})
+	}
+
+	reconciler := newTestReconciler(objects)
+	reconciler.IngressMaxSlots = 2
+	reconciler.EnableBucketCompaction = true
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: "my-namespace",
+			Name:      "kubernetes-shared-ingress",
+		},
+	}
+
+	_, err := reconciler.Reconcile(ctx, request)
+	require.NoError(t, err)
+
+	// the source is added to the target before being removed from the drained result
+	sharedIngresses, err := setSharedIngressesLB(ctx, reconciler.Client, "my-namespace", map[string]string{})
+	require.NoError(t, err)
+	require.Len(t, sharedIngresses, 2)
+	// both sources
+	assert.Len(t, sharedIngresses[0].OwnerReferences, 2)
+	assert.Len(t, sharedIngresses[0].Spec.Rules, 2)
+	assert.Equal(t, "true", sharedIngresses[1].Annotations[DrainingAnnotation])
+	assert.Len(t, sharedIngresses[1].Spec.Rules, 1)
+
+	_, err = reconciler.Reconcile(ctx, request)
+	require.NoError(t, err)
+
+	sharedIngresses, err = getSharedIngresses(ctx, reconciler.Client, "my-namespace")
+	require.NoError(t, err)
+	require.Len(t, sharedIngresses, 1)
+	assert.Equal(t, "kubernetes-shared-ingress", sharedIngresses[0].Name)
+	assert.Len(t, sharedIngresses[0].OwnerReferences, 2)
+}
+
+func TestReconcileMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	objects := []runtime.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "metrics-namespace",
+				Name:      "kubernetes-shared-ingress",
+			},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "metrics-namespace",
+				Name:      "missing-config",
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+				},
+			},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "metrics-namespace",
+				Name:      "invalid-priority",
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+					ConfigAnnotation:       "kubernetes-shared-ingress",
+					PriorityAnnotation:     "high",
+				},
+			},
+		},
+	}
+	for i := 0; i < 3; i++ {
+		objects = append(objects, &networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "metrics-namespace",
+				Name:      fmt.Sprintf("my-instance-%d", i),
+				Annotations: map[string]string{
+					IngressClassAnnotation: "merge",
+					ConfigAnnotation:       "kubernetes-shared-ingress",
+				},
+			},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					{
+						Host: fmt.Sprintf("instance%d.example.org", i),
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	reconciler := newTestReconciler(objects)
+	reconciler.IngressMaxSlots = 2
+	createdBefore := testutil.ToFloat64(operationsMetric.WithLabelValues(createOperation))
+
+	request := mergeGroupRequest("metrics-namespace", "kubernetes-shared-ingress")
+	_, err := reconciler.Reconcile(ctx, request)
+	require.NoError(t, err)
+	_, err = reconciler.Reconcile(ctx, mergeGroupRequest("metrics-namespace", ""))
+	require.NoError(t, err)
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(sourceIngressesMetric.WithLabelValues("metrics-namespace", "kubernetes-shared-ingress")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(resultIngressesMetric.WithLabelValues("metrics-namespace", "kubernetes-shared-ingress")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(bucketUsedSlotsMetric.WithLabelValues("metrics-namespace", "kubernetes-shared-ingress", "kubernetes-shared-ingress")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(bucketUsedSlotsMetric.WithLabelValues("metrics-namespace", "kubernetes-shared-ingress", "kubernetes-shared-ingress-1")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(bucketMaxSlotsMetric.WithLabelValues("metrics-namespace", "kubernetes-shared-ingress", "kubernetes-shared-ingress-1")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(skippedIngressesMetric.WithLabelValues("metrics-namespace", "", MissingConfigReason)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(skippedIngressesMetric.WithLabelValues("metrics-namespace", "kubernetes-shared-ingress", InvalidPriorityReason)))
+	assert.Equal(t, createdBefore+2, testutil.ToFloat64(operationsMetric.WithLabelValues(createOperation)))
+
+	// skipped ingresses are counted once whatever the number of reconciles
+	_, err = reconciler.Reconcile(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(skippedIngressesMetric.WithLabelValues("metrics-namespace", "kubernetes-shared-ingress", InvalidPriorityReason)))
+
+	// the series of a config are deleted along with its sources
+	for i := 0; i < 3; i++ {
+		require.NoError(t, reconciler.Client.Delete(ctx, &networkingv1.Ingress{
+			ObjectMeta: metaV1.ObjectMeta{Namespace: "metrics-namespace", Name: fmt.Sprintf("my-instance-%d", i)},
+		}))
+	}
+	require.NoError(t, reconciler.Client.Delete(ctx, &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "metrics-namespace", Name: "invalid-priority"},
+	}))
+
+	_, err = reconciler.Reconcile(ctx, request)
+	require.NoError(t, err)
+
+	assert.False(t, sourceIngressesMetric.DeleteLabelValues("metrics-namespace", "kubernetes-shared-ingress"))
+	assert.False(t, resultIngressesMetric.DeleteLabelValues("metrics-namespace", "kubernetes-shared-ingress"))
+	assert.False(t, bucketUsedSlotsMetric.DeleteLabelValues("metrics-namespace", "kubernetes-shared-ingress", "kubernetes-shared-ingress"))
+	assert.False(t, skippedIngressesMetric.DeleteLabelValues("metrics-namespace", "kubernetes-shared-ingress", InvalidPriorityReason))
+}
+
+func TestReconcileMetricsDeletedConfig(t *testing.T) {
+	ctx := context.Background()
+
+	reconciler := newTestReconciler([]runtime.Object{
+		newTestSource("my-instance", "deleted-config"),
+		newTestConfigMap("deleted-config", nil),
+	})
+	request := mergeGroupRequest("my-namespace", "deleted-config")
+
+	_, err := reconciler.Reconcile(ctx, request)
+	require.NoError(t, err)
+	assert.Greater(t, testutil.ToFloat64(bucketUsedSlotsMetric.WithLabelValues("my-namespace", "deleted-config", "deleted-config")), float64(0))
+
+	// the result ingress goes away with its owners, without being cleaned up
+	require.NoError(t, reconciler.Client.Delete(ctx, newTestConfigMap("deleted-config", nil)))
+	require.NoError(t, reconciler.Client.Delete(ctx, newTestSource("my-instance", "deleted-config")))
+	collectGarbage(ctx, t, reconciler.Client, "my-namespace")
+	err = reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "my-namespace", Name: "deleted-config"}, &networkingv1.Ingress{})
+	require.True(t, k8sErrors.IsNotFound(err))
+
+	_, err = reconciler.Reconcile(ctx, request)
+	require.NoError(t, err)
+
+	assert.False(t, bucketUsedSlotsMetric.DeleteLabelValues("my-namespace", "deleted-config", "deleted-config"))
+	assert.False(t, bucketMaxSlotsMetric.DeleteLabelValues("my-namespace", "deleted-config", "deleted-config"))
+}
+
+// collectGarbage deletes the ingresses of a namespace whose owners are all
+// gone, like the garbage collector of Kubernetes does.
+func collectGarbage(ctx context.Context, t *testing.T, cli client.Client, namespace string) {
+	ingresses := networkingv1.IngressList{}
+	require.NoError(t, cli.List(ctx, &ingresses, client.InNamespace(namespace)))
+
+	for i := range ingresses.Items {
+		ingress := &ingresses.Items[i]
+		if len(ingress.OwnerReferences) == 0 {
+			continue
+		}
+
+		orphan := true
+		for _, ownerReference := range ingress.OwnerReferences {
+			var owner client.Object
+			switch ownerReference.Kind {
+			case "Ingress":
+				owner = &networkingv1.Ingress{}
+			case "ConfigMap":
+				owner = &corev1.ConfigMap{}
+			case "IngressMerge":
+				owner = &mergev1alpha1.IngressMerge{}
+			}
+
+			err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ownerReference.Name}, owner)
+			if err == nil {
+				orphan = false
+				break
+			}
+			require.True(t, k8sErrors.IsNotFound(err))
+		}
+
+		if orphan {
+			require.NoError(t, cli.Delete(ctx, ingress))
+		}
+	}
+}
+
 func setSharedIngressesLB(ctx context.Context, cli client.Client, namespace string, labels map[string]string) ([]networkingv1.Ingress, error) {
 	sharedIngresses, err := getSharedIngresses(ctx, cli, namespace)
 	if err != nil {
@@ -684,9 +1921,11 @@ func getSharedIngresses(ctx context.Context, cli client.Client, namespace string
 	return sharedIngresses, nil
 }
 
+// updateSharedIngresses updates result ingresses as the ingress provider
+// would, under its own field manager.
 func updateSharedIngresses(ctx context.Context, cli client.Client, sharedIngresses []networkingv1.Ingress, namespace string) error {
 	for _, sharedIngress := range sharedIngresses {
-		err := cli.Update(ctx, &sharedIngress)
+		err := cli.Update(ctx, &sharedIngress, client.FieldOwner("ingress-provider"))
 		if err != nil {
 			return err
 		}
@@ -698,15 +1937,18 @@ func newTestReconciler(objs []runtime.Object) *IngressReconciler {
 	scheme := runtime.NewScheme()
 	_ = networkingv1.AddToScheme(scheme)
 	_ = corev1.AddToScheme(scheme)
+	_ = mergev1alpha1.AddToScheme(scheme)
 
 	reconciler := &IngressReconciler{
-		IngressMaxSlots: 45,
-		Log:             zap.New(zap.UseDevMode(true)),
-		IngressClass:    "merge",
-		Client: fake.NewClientBuilder().
+		IngressMaxSlots:    45,
+		Log:                zap.New(zap.UseDevMode(true)),
+		Recorder:           record.NewFakeRecorder(1000),
+		IngressClass:       "merge",
+		EnableIngressMerge: true,
+		Client: applyClient{fake.NewClientBuilder().
 			WithScheme(scheme).
 			WithRuntimeObjects(objs...).
-			Build(),
+			Build()},
 	}
 
 	return reconciler
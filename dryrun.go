@@ -0,0 +1,35 @@
+package ingress_merge
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const dryRunEventPrefix = "dry run: "
+
+// dryRunRecorder marks the events of a dry run, so they are not taken for
+// changes made by the controller.
+type dryRunRecorder struct {
+	record.EventRecorder
+}
+
+func (r *dryRunRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.EventRecorder.Event(object, eventtype, reason, dryRunEventPrefix+message)
+}
+
+func (r *dryRunRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.Eventf(object, eventtype, reason, dryRunEventPrefix+messageFmt, args...)
+}
+
+func (r *dryRunRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, dryRunEventPrefix+messageFmt, args...)
+}
+
+// enableDryRun sends every write of the reconciler with server-side dry-run,
+// the API server validates them but does not persist anything.
+func (r *IngressReconciler) enableDryRun() {
+	r.Client = client.NewDryRunClient(r.Client)
+	r.Recorder = &dryRunRecorder{r.Recorder}
+	r.Log = r.Log.WithValues("dry_run", true)
+}
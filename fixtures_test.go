@@ -0,0 +1,152 @@
+package ingress_merge
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// newTestIngress returns an ingress of my-namespace with a rule for host,
+// whose paths are served by the service named after the ingress. Its UID is
+// its name, which the owner references of newTestResult rely on.
+func newTestIngress(name, host string, paths ...string) networkingv1.Ingress {
+	httpPaths := []networkingv1.HTTPIngressPath{}
+	for _, path := range paths {
+		httpPaths = append(httpPaths, networkingv1.HTTPIngressPath{
+			Path: path,
+			Backend: networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: name,
+					Port: networkingv1.ServiceBackendPort{
+						Number: 80,
+					},
+				},
+			},
+		})
+	}
+
+	return networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      name,
+			UID:       types.UID(name),
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: httpPaths,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// numberedPaths returns n distinct paths of an ingress, each using a slot.
+func numberedPaths(name string, n int) []string {
+	paths := []string{}
+	for i := 0; i < n; i++ {
+		paths = append(paths, fmt.Sprintf("/%s-%d", name, i))
+	}
+
+	return paths
+}
+
+// newTestSource returns a source ingress of the merge ingress class
+// referencing a config, with a rule for <name>.example.org.
+func newTestSource(name, configName string) *networkingv1.Ingress {
+	ingress := withAnnotations(newTestIngress(name, name+".example.org"), map[string]string{
+		IngressClassAnnotation: "merge",
+		ConfigAnnotation:       configName,
+	})
+
+	return &ingress
+}
+
+// newTestResult returns a result ingress of a config merging the given
+// source ingresses.
+func newTestResult(name, configName string, sources ...string) *networkingv1.Ingress {
+	result := &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      name,
+			UID:       types.UID(name),
+			Annotations: map[string]string{
+				ResultAnnotation:     "true",
+				FromConfigAnnotation: configName,
+			},
+		},
+	}
+	for _, source := range sources {
+		result.OwnerReferences = append(result.OwnerReferences, metaV1.OwnerReference{
+			Kind: "Ingress",
+			Name: source,
+			UID:  types.UID(source),
+		})
+	}
+
+	return result
+}
+
+func newTestConfigMap(name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "my-namespace",
+			Name:      name,
+		},
+		Data: data,
+	}
+}
+
+// withAnnotations adds annotations to a copy of an ingress.
+func withAnnotations(ingress networkingv1.Ingress, annotations map[string]string) networkingv1.Ingress {
+	merged := make(map[string]string)
+	for k, v := range ingress.Annotations {
+		merged[k] = v
+	}
+	for k, v := range annotations {
+		merged[k] = v
+	}
+	ingress.Annotations = merged
+
+	return ingress
+}
+
+// withTLS serves the host of an ingress with a TLS secret.
+func withTLS(ingress networkingv1.Ingress, secretName string) networkingv1.Ingress {
+	ingress.Spec.TLS = []networkingv1.IngressTLS{
+		{Hosts: []string{ingress.Spec.Rules[0].Host}, SecretName: secretName},
+	}
+
+	return ingress
+}
+
+func createdAt(ingress networkingv1.Ingress, created time.Time) networkingv1.Ingress {
+	ingress.CreationTimestamp = metaV1.NewTime(created)
+	return ingress
+}
+
+// bucketNames returns the names of the ingresses of every bucket, by name of
+// its result ingress, empty for the buckets without one.
+func bucketNames(buckets []*IngressBucket) map[string][]string {
+	names := make(map[string][]string)
+	for _, bucket := range buckets {
+		name := ""
+		if bucket.DestinationIngress != nil {
+			name = bucket.DestinationIngress.Name
+		}
+		for _, ingress := range bucket.Ingresses {
+			names[name] = append(names[name], ingress.Name)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,71 @@
+package ingress_merge
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// mergeGroupRequest is the request of a merge group, the source ingresses of
+// a namespace referencing the same config along with their result ingresses.
+// Events of a group are deduplicated by the workqueue, which hands a request
+// to a single worker at a time.
+func mergeGroupRequest(ns, configName string) reconcile.Request {
+	return reconcile.Request{
+		NamespacedName: client.ObjectKey{
+			Namespace: ns,
+			Name:      configName,
+		},
+	}
+}
+
+// ingressGroups returns the merge group of an ingress. A source ingress
+// without config annotation maps to the request without name of its
+// namespace, which reports it.
+func (r *IngressReconciler) ingressGroups(obj client.Object) []reconcile.Request {
+	annotations := obj.GetAnnotations()
+	if annotations[ResultAnnotation] == "true" {
+		return []reconcile.Request{mergeGroupRequest(obj.GetNamespace(), annotations[FromConfigAnnotation])}
+	}
+
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok || getIngressClass(ingress) != r.IngressClass {
+		return nil
+	}
+
+	return []reconcile.Request{mergeGroupRequest(obj.GetNamespace(), annotations[ConfigAnnotation])}
+}
+
+// ingressGroupHandler enqueues the merge groups of ingress events. An update
+// enqueues the groups of both versions of the ingress, so an ingress moving
+// to another config or leaving the ingress class is removed from the result
+// ingresses of its previous group. Events are recorded by the settler, which
+// holds the groups back while they keep changing.
+func (r *IngressReconciler) ingressGroupHandler() handler.EventHandler {
+	enqueue := func(q workqueue.RateLimitingInterface, objs ...client.Object) {
+		for _, obj := range objs {
+			for _, req := range r.ingressGroups(obj) {
+				r.settler.observe(req)
+				q.Add(req)
+			}
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(q, e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(q, e.ObjectOld, e.ObjectNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			enqueue(q, e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+			enqueue(q, e.Object)
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package ingress_merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestIngressGroupHandler(t *testing.T) {
+	source := newTestSource("my-instance", "shared")
+	moved := source.DeepCopy()
+	moved.Annotations[ConfigAnnotation] = "other"
+	otherClass := source.DeepCopy()
+	otherClass.Annotations[IngressClassAnnotation] = "nginx"
+	missingConfig := newTestSource("missing-config", "")
+	delete(missingConfig.Annotations, ConfigAnnotation)
+	result := newTestResult("shared", "shared")
+
+	tests := []struct {
+		name     string
+		send     func(q workqueue.RateLimitingInterface)
+		requests []reconcile.Request
+	}{
+		{
+			name: "source created",
+			send: func(q workqueue.RateLimitingInterface) {
+				newTestReconciler(nil).ingressGroupHandler().Create(event.CreateEvent{Object: source}, q)
+			},
+			requests: []reconcile.Request{mergeGroupRequest("my-namespace", "shared")},
+		},
+		{
+			name: "source moved to another config",
+			send: func(q workqueue.RateLimitingInterface) {
+				newTestReconciler(nil).ingressGroupHandler().Update(event.UpdateEvent{ObjectOld: source, ObjectNew: moved}, q)
+			},
+			requests: []reconcile.Request{mergeGroupRequest("my-namespace", "shared"), mergeGroupRequest("my-namespace", "other")},
+		},
+		{
+			name: "source leaving the ingress class",
+			send: func(q workqueue.RateLimitingInterface) {
+				newTestReconciler(nil).ingressGroupHandler().Update(event.UpdateEvent{ObjectOld: source, ObjectNew: otherClass}, q)
+			},
+			requests: []reconcile.Request{mergeGroupRequest("my-namespace", "shared")},
+		},
+		{
+			name: "source without config",
+			send: func(q workqueue.RateLimitingInterface) {
+				newTestReconciler(nil).ingressGroupHandler().Create(event.CreateEvent{Object: missingConfig}, q)
+			},
+			requests: []reconcile.Request{mergeGroupRequest("my-namespace", "")},
+		},
+		{
+			name: "result and source of the same group",
+			send: func(q workqueue.RateLimitingInterface) {
+				handler := newTestReconciler(nil).ingressGroupHandler()
+				handler.Update(event.UpdateEvent{ObjectOld: result, ObjectNew: result}, q)
+				handler.Delete(event.DeleteEvent{Object: source}, q)
+			},
+			requests: []reconcile.Request{mergeGroupRequest("my-namespace", "shared")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer q.ShutDown()
+
+			tt.send(q)
+
+			requests := []reconcile.Request{}
+			for q.Len() > 0 {
+				item, _ := q.Get()
+				requests = append(requests, item.(reconcile.Request))
+				q.Done(item)
+			}
+			assert.ElementsMatch(t, tt.requests, requests)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package ingress_merge
+
+import (
+	"context"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Fields of the ingress indexes of the manager cache.
+const (
+	configIndexField       = "index.merge.ingress.kubernetes.io/config"
+	fromConfigIndexField   = "index.merge.ingress.kubernetes.io/from-config"
+	ingressClassIndexField = "index.merge.ingress.kubernetes.io/ingress-class"
+)
+
+// indexConfig indexes source ingresses by the config they reference.
+func indexConfig(obj client.Object) []string {
+	if obj.GetAnnotations()[ResultAnnotation] == "true" {
+		return nil
+	}
+
+	configName, exists := obj.GetAnnotations()[ConfigAnnotation]
+	if !exists {
+		return nil
+	}
+
+	return []string{configName}
+}
+
+// indexFromConfig indexes result ingresses by the config they are created
+// from.
+func indexFromConfig(obj client.Object) []string {
+	if obj.GetAnnotations()[ResultAnnotation] != "true" {
+		return nil
+	}
+
+	return []string{obj.GetAnnotations()[FromConfigAnnotation]}
+}
+
+// indexIngressClass indexes source ingresses by their effective ingress
+// class.
+func indexIngressClass(obj client.Object) []string {
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok || ingress.Annotations[ResultAnnotation] == "true" {
+		return nil
+	}
+
+	return []string{getIngressClass(ingress)}
+}
+
+// indexFields registers the ingress indexes on the manager cache, so the
+// ingresses of a merge group are listed without going through the whole
+// namespace.
+func (r *IngressReconciler) indexFields(ctx context.Context, indexer client.FieldIndexer) error {
+	for _, index := range []struct {
+		field   string
+		extract client.IndexerFunc
+	}{
+		{configIndexField, indexConfig},
+		{fromConfigIndexField, indexFromConfig},
+		{ingressClassIndexField, indexIngressClass},
+	} {
+		if err := indexer.IndexField(ctx, &networkingv1.Ingress{}, index.field, index.extract); err != nil {
+			return err
+		}
+	}
+
+	r.indexed = true
+
+	return nil
+}
+
+func (r *IngressReconciler) listIngresses(ctx context.Context, ns string, selector labels.Selector, field, value string) ([]networkingv1.Ingress, error) {
+	return listIndexedIngresses(ctx, r.Client, r.indexed, ns, selector, field, value)
+}
+
+// listIndexedIngresses lists the ingresses of a namespace matching a
+// selector, narrowed down to the ones whose indexed field has the given
+// value when the indexes are registered. Without indexes, e.g. when reading
+// straight from the API server, or without field, the whole namespace is
+// listed, so callers still filter the ingresses themselves.
+func listIndexedIngresses(ctx context.Context, reader client.Reader, indexed bool, ns string, selector labels.Selector, field, value string) ([]networkingv1.Ingress, error) {
+	opts := &client.ListOptions{
+		Namespace:     ns,
+		LabelSelector: selector,
+	}
+	if indexed && field != "" {
+		opts.FieldSelector = fields.OneTermEqualSelector(field, value)
+	}
+
+	ingresses := &networkingv1.IngressList{}
+	if err := reader.List(ctx, ingresses, opts); err != nil {
+		return nil, err
+	}
+
+	return ingresses.Items, nil
+}
@@ -0,0 +1,44 @@
+package ingress_merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIndexes(t *testing.T) {
+	ingressClassName := "other"
+	source := &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: "my-instance",
+			Annotations: map[string]string{
+				IngressClassAnnotation: "merge",
+				ConfigAnnotation:       "shared",
+			},
+		},
+	}
+	sourceWithClassName := source.DeepCopy()
+	sourceWithClassName.Spec.IngressClassName = &ingressClassName
+	result := &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: "shared",
+			Annotations: map[string]string{
+				ResultAnnotation:     "true",
+				FromConfigAnnotation: "shared",
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"shared"}, indexConfig(source))
+	assert.Nil(t, indexConfig(result))
+	assert.Nil(t, indexConfig(&networkingv1.Ingress{}))
+
+	assert.Nil(t, indexFromConfig(source))
+	assert.Equal(t, []string{"shared"}, indexFromConfig(result))
+
+	assert.Equal(t, []string{"merge"}, indexIngressClass(source))
+	assert.Equal(t, []string{"other"}, indexIngressClass(sourceWithClassName))
+	assert.Nil(t, indexIngressClass(result))
+}
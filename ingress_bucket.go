@@ -1,6 +1,7 @@
 package ingress_merge
 
 import (
+	"fmt"
 	"sort"
 
 	networkingv1 "k8s.io/api/networking/v1"
@@ -11,9 +12,81 @@ type IngressBucket struct {
 	FreeSlots          int
 	Ingresses          []networkingv1.Ingress
 	DestinationIngress *networkingv1.Ingress
+
+	// Draining marks a bucket whose sources are being moved to other
+	// buckets, no source is added to it and it is deleted once empty.
+	Draining bool
+	// Incoming holds the names of the sources moved into the bucket that
+	// are still served by a draining bucket, their status is propagated
+	// from the draining bucket meanwhile.
+	Incoming map[string]bool
+
+	counter SlotCounter
+	limits  BucketLimits
+	usage   bucketUsage
+}
+
+func (b *IngressBucket) slots(ingress *networkingv1.Ingress) int {
+	if b.counter == nil {
+		return ingressSlots(ingress)
+	}
+
+	return b.counter.Slots(ingress)
+}
+
+func (b *IngressBucket) add(ingress networkingv1.Ingress) {
+	b.Ingresses = append(b.Ingresses, ingress)
+	b.FreeSlots -= b.slots(&ingress)
+	b.usage.add(&ingress)
+}
+
+func (b *IngressBucket) remove(ingress networkingv1.Ingress) {
+	for i := range b.Ingresses {
+		if b.Ingresses[i].Name == ingress.Name {
+			b.Ingresses = append(b.Ingresses[:i], b.Ingresses[i+1:]...)
+			b.FreeSlots += b.slots(&ingress)
+			b.usage.remove(&ingress)
+			return
+		}
+	}
+}
+
+// exceeded describes the free slots and limits of the bucket the ingresses
+// would go beyond once added.
+func (b *IngressBucket) exceeded(ingresses []networkingv1.Ingress) []string {
+	exceeded := []string{}
+
+	slots := 0
+	for i := range ingresses {
+		slots += b.slots(&ingresses[i])
+	}
+	if slots > b.FreeSlots {
+		exceeded = append(exceeded, fmt.Sprintf("%d slots of %d free", slots, b.FreeSlots))
+	}
+
+	return append(exceeded, b.limits.exceeded(b.usage.with(ingresses))...)
+}
+
+func (b *IngressBucket) fits(ingresses []networkingv1.Ingress) bool {
+	return len(b.exceeded(ingresses)) == 0
+}
+
+func (b *IngressBucket) markIncoming(ingress *networkingv1.Ingress) {
+	if b.Incoming == nil {
+		b.Incoming = make(map[string]bool)
+	}
+	b.Incoming[ingress.Name] = true
 }
 
 func GenerateIngressBuckets(origins, destinations []networkingv1.Ingress, maxServices int) []*IngressBucket {
+	return GenerateIngressBucketsWithStrategy(origins, destinations, BucketCapacity{MaxSlots: maxServices}, FirstFitPacking{})
+}
+
+// GenerateIngressBucketsWithStrategy keeps the origins in the bucket of the
+// destination they are merged into, and places the other ones with the
+// packing strategy. Origins sharing a host are always placed in the same
+// bucket, even when they need more slots than a bucket has together.
+func GenerateIngressBucketsWithStrategy(origins, destinations []networkingv1.Ingress, capacity BucketCapacity, strategy PackingStrategy) []*IngressBucket {
 	type dependencyKey struct {
 		name string
 		uid  types.UID
@@ -21,16 +94,17 @@ func GenerateIngressBuckets(origins, destinations []networkingv1.Ingress, maxSer
 
 	bucketsWithDestinationMap := map[dependencyKey]*IngressBucket{}
 	bucketsWithDestination := []*IngressBucket{}
-	bucketsWithoutDestination := []*IngressBucket{}
-	ingressesWithoutDestination := []networkingv1.Ingress{}
-	originToDestinationMap := map[dependencyKey]*networkingv1.Ingress{}
+	groupsWithoutDestination := [][]networkingv1.Ingress{}
+	originToBucketMap := map[dependencyKey]*IngressBucket{}
+	originToDestinationMap := map[dependencyKey][]*IngressBucket{}
+	drainingOrigins := map[dependencyKey][]*IngressBucket{}
 
 	for i := range destinations {
 		k := dependencyKey{destinations[i].Name, destinations[i].UID}
-		bucketsWithDestinationMap[k] = &IngressBucket{
-			FreeSlots:          maxServices,
-			DestinationIngress: &destinations[i],
-		}
+		bucket := capacity.newBucket()
+		bucket.DestinationIngress = &destinations[i]
+		bucket.Draining = destinations[i].Annotations[DrainingAnnotation] == "true"
+		bucketsWithDestinationMap[k] = bucket
 
 		for _, ownerReference := range destinations[i].OwnerReferences {
 			if ownerReference.Kind != "Ingress" {
@@ -38,7 +112,7 @@ func GenerateIngressBuckets(origins, destinations []networkingv1.Ingress, maxSer
 			}
 
 			k = dependencyKey{ownerReference.Name, ownerReference.UID}
-			originToDestinationMap[k] = &destinations[i]
+			originToDestinationMap[k] = append(originToDestinationMap[k], bucket)
 		}
 	}
 
@@ -49,76 +123,270 @@ func GenerateIngressBuckets(origins, destinations []networkingv1.Ingress, maxSer
 	for _, origin := range origins {
 		k := dependencyKey{origin.Name, origin.UID}
 
-		if originToDestinationMap[k] != nil {
-			destination := originToDestinationMap[k]
-			destinationKey := dependencyKey{destination.Name, destination.UID}
-			bucketsWithDestinationMap[destinationKey].Ingresses = append(bucketsWithDestinationMap[destinationKey].Ingresses, origin)
-			bucketsWithDestinationMap[destinationKey].FreeSlots -= ingressSlots(&origin)
-			continue
+		// an origin owned by a draining bucket is moving to another one
+		var destination *IngressBucket
+		for _, bucket := range originToDestinationMap[k] {
+			if bucket.Draining {
+				drainingOrigins[k] = append(drainingOrigins[k], bucket)
+			} else if destination == nil {
+				destination = bucket
+			}
 		}
 
-		ingressesWithoutDestination = append(ingressesWithoutDestination, origin)
+		if destination != nil {
+			destination.add(origin)
+			originToBucketMap[k] = destination
+		}
 	}
 
-	sort.Slice(ingressesWithoutDestination, func(i, j int) bool {
-		slotsI := ingressSlots(&ingressesWithoutDestination[i])
-		slotsJ := ingressSlots(&ingressesWithoutDestination[j])
+	for _, group := range HostGroups(origins) {
+		slotsInBucket := map[*IngressBucket]int{}
+		var target *IngressBucket
 
-		if slotsI != slotsJ {
-			return slotsI < slotsJ
+		// the bucket already serving most of the group keeps it
+		for i := range group {
+			bucket := originToBucketMap[dependencyKey{group[i].Name, group[i].UID}]
+			if bucket == nil {
+				continue
+			}
+
+			slotsInBucket[bucket] += bucket.slots(&group[i])
+			if target == nil || slotsInBucket[bucket] > slotsInBucket[target] {
+				target = bucket
+			}
 		}
 
-		return ingressesWithoutDestination[i].ObjectMeta.CreationTimestamp.After(ingressesWithoutDestination[j].ObjectMeta.CreationTimestamp.Time)
-	})
+		moving := []networkingv1.Ingress{}
+		for i := range group {
+			if originToBucketMap[dependencyKey{group[i].Name, group[i].UID}] != target {
+				moving = append(moving, group[i])
+			}
+		}
+
+		if target != nil && (len(moving) == 0 || target.fits(moving) || !capacity.fits(group)) {
+			for i := range group {
+				bucket := originToBucketMap[dependencyKey{group[i].Name, group[i].UID}]
+				if bucket == target {
+					continue
+				}
+
+				if bucket != nil {
+					bucket.remove(group[i])
+				}
+				target.add(group[i])
+			}
+			continue
+		}
+
+		// otherwise the whole group moves to a bucket with room for it
+		for i := range group {
+			if bucket := originToBucketMap[dependencyKey{group[i].Name, group[i].UID}]; bucket != nil {
+				bucket.remove(group[i])
+			}
+		}
+
+		groupsWithoutDestination = append(groupsWithoutDestination, group)
+	}
 
 	sort.Slice(bucketsWithDestination, func(i, j int) bool {
-		return bucketsWithDestination[i].FreeSlots > bucketsWithDestination[j].FreeSlots
+		if bucketsWithDestination[i].FreeSlots != bucketsWithDestination[j].FreeSlots {
+			return bucketsWithDestination[i].FreeSlots > bucketsWithDestination[j].FreeSlots
+		}
+
+		return bucketsWithDestination[i].DestinationIngress.Name < bucketsWithDestination[j].DestinationIngress.Name
 	})
 
-	var currentBucket *IngressBucket
-	reuseBucketsWithAddrPos := -1
+	bucketsWithoutDestination := strategy.Pack(groupsWithoutDestination, bucketsWithDestination, capacity)
 
-	nextBucket := func() {
-		for reuseBucketsWithAddrPos < len(bucketsWithDestination)-1 {
-			reuseBucketsWithAddrPos++
-			currentBucket = bucketsWithDestination[reuseBucketsWithAddrPos]
+	result := []*IngressBucket{}
+	result = append(result, bucketsWithDestination...)
+	result = append(result, bucketsWithoutDestination...)
 
-			if currentBucket.FreeSlots > 0 {
-				return
+	// draining buckets keep serving their origins until the buckets the
+	// origins moved to have been written with them and have an address
+	for _, bucket := range result {
+		if bucket.Draining {
+			continue
+		}
+
+		for i := range bucket.Ingresses {
+			k := dependencyKey{bucket.Ingresses[i].Name, bucket.Ingresses[i].UID}
+			if len(drainingOrigins[k]) == 0 {
+				continue
+			}
+
+			written := false
+			for _, destination := range originToDestinationMap[k] {
+				written = written || destination == bucket
+			}
+			if written && hasAddress(bucket.DestinationIngress) {
+				continue
+			}
+
+			bucket.markIncoming(&bucket.Ingresses[i])
+			for _, drainingBucket := range drainingOrigins[k] {
+				drainingBucket.add(bucket.Ingresses[i])
 			}
 		}
-		currentBucket = &IngressBucket{
-			FreeSlots: maxServices,
+	}
+
+	return result
+}
+
+// CompactIngressBuckets drains the least used buckets into the free slots of
+// the other ones, as long as the used slots fit into fewer buckets. Only
+// buckets of existing result ingresses take part. The sources of a drained
+// bucket are added to their new bucket while being kept in the drained one,
+// which GenerateIngressBuckets releases once the result ingress of the new
+// bucket has been written with them and has an address.
+// It returns the buckets that started draining.
+func CompactIngressBuckets(buckets []*IngressBucket, capacity BucketCapacity) []*IngressBucket {
+	// without slots the needed buckets cannot be counted
+	if capacity.MaxSlots < 1 {
+		return nil
+	}
+
+	candidates := []*IngressBucket{}
+	usedSlots := 0
+
+	for _, bucket := range buckets {
+		// buckets still waiting on a migration are left alone
+		if bucket.DestinationIngress == nil || bucket.Draining || len(bucket.Incoming) > 0 || len(bucket.Ingresses) == 0 {
+			continue
 		}
-		bucketsWithoutDestination = append(bucketsWithoutDestination, currentBucket)
+
+		candidates = append(candidates, bucket)
+		usedSlots += capacity.MaxSlots - bucket.FreeSlots
 	}
 
-	if len(ingressesWithoutDestination) > 0 {
-		nextBucket()
+	neededBuckets := (usedSlots + capacity.MaxSlots - 1) / capacity.MaxSlots
+	if neededBuckets < 1 {
+		neededBuckets = 1
 	}
 
-	for _, ingress := range ingressesWithoutDestination {
-		slots := ingressSlots(&ingress)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].FreeSlots != candidates[j].FreeSlots {
+			return candidates[i].FreeSlots > candidates[j].FreeSlots
+		}
+
+		// the last result ingresses are drained first
+		return candidates[i].DestinationIngress.Name > candidates[j].DestinationIngress.Name
+	})
+
+	drained := []*IngressBucket{}
 
-		if currentBucket.FreeSlots-slots < 0 {
-			nextBucket()
+	for _, bucket := range candidates {
+		if len(candidates)-len(drained) <= neededBuckets {
+			break
 		}
 
-		currentBucket.Ingresses = append(currentBucket.Ingresses, ingress)
-		currentBucket.FreeSlots -= slots
+		if len(bucket.Incoming) > 0 {
+			continue
+		}
+
+		targets := []*IngressBucket{}
+		for _, target := range candidates {
+			if target != bucket && !target.Draining {
+				targets = append(targets, target)
+			}
+		}
+
+		groups := HostGroups(bucket.Ingresses)
+		moves, ok := planBucketMoves(groups, targets, capacity)
+		if !ok {
+			continue
+		}
+
+		for i, target := range moves {
+			for j := range groups[i] {
+				target.add(groups[i][j])
+				target.markIncoming(&groups[i][j])
+			}
+		}
+
+		bucket.Draining = true
+		drained = append(drained, bucket)
 	}
 
-	result := []*IngressBucket{}
-	result = append(result, bucketsWithDestination...)
-	result = append(result, bucketsWithoutDestination...)
-	return result
+	return drained
+}
+
+// planBucketMoves finds a target with enough free slots and within its limits
+// for every group of ingresses of a bucket, biggest groups first into the fullest target they
+// fit.
+func planBucketMoves(groups [][]networkingv1.Ingress, targets []*IngressBucket, capacity BucketCapacity) ([]*IngressBucket, bool) {
+	freeSlots := make(map[*IngressBucket]int, len(targets))
+	usage := make(map[*IngressBucket]bucketUsage, len(targets))
+	for _, target := range targets {
+		freeSlots[target] = target.FreeSlots
+		usage[target] = target.usage.with(nil)
+	}
+
+	order := make([]int, len(groups))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return capacity.slots(groups[order[i]]) > capacity.slots(groups[order[j]])
+	})
+
+	moves := make([]*IngressBucket, len(groups))
+	for _, i := range order {
+		slots := capacity.slots(groups[i])
+
+		for _, target := range targets {
+			if freeSlots[target] < slots || len(target.limits.exceeded(usage[target].with(groups[i]))) > 0 {
+				continue
+			}
+
+			if moves[i] == nil || freeSlots[target] < freeSlots[moves[i]] {
+				moves[i] = target
+			}
+		}
+
+		if moves[i] == nil {
+			return nil, false
+		}
+
+		freeSlots[moves[i]] -= slots
+		usage[moves[i]] = usage[moves[i]].with(groups[i])
+	}
+
+	return moves, true
+}
+
+// HostGroups groups together the ingresses sharing a host, which must be
+// served by the same result ingress.
+func HostGroups(ingresses []networkingv1.Ingress) [][]networkingv1.Ingress {
+	return groupIngresses(ingresses, ingressHosts)
+}
+
+// UnschedulableHostGroups returns the groups of ingresses sharing a host that
+// need more slots or resources than a bucket has together.
+func UnschedulableHostGroups(ingresses []networkingv1.Ingress, capacity BucketCapacity) [][]networkingv1.Ingress {
+	groups := [][]networkingv1.Ingress{}
+	for _, group := range HostGroups(ingresses) {
+		if !capacity.fits(group) {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+func hasAddress(ingress *networkingv1.Ingress) bool {
+	return ingress != nil && len(ingress.Status.LoadBalancer.Ingress) > 0
 }
 
 func ingressSlots(ingress *networkingv1.Ingress) int {
 	slots := 0
 
 	for _, rule := range ingress.Spec.Rules {
-		paths := len(rule.HTTP.Paths)
+		paths := 0
+		if rule.HTTP != nil {
+			paths = len(rule.HTTP.Paths)
+		}
 		if paths == 0 {
 			paths = 1
 		}
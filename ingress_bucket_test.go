@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -260,3 +261,164 @@ func TestGenerateIngressBucketsWithOverflow(t *testing.T) {
 	assert.Len(t, ingressBuckets[3].Ingresses, 1)
 	assert.Equal(t, -30, ingressBuckets[3].FreeSlots)
 }
+
+func TestCompactIngressBuckets(t *testing.T) {
+	origins := []networkingv1.Ingress{}
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("origin-%d", i)
+		origins = append(origins, newTestIngress(name, name+".example.org"))
+	}
+
+	destinations := []networkingv1.Ingress{
+		*newTestResult("shared-01", "shared", "origin-0", "origin-1", "origin-2"),
+		*newTestResult("shared-02", "shared", "origin-3", "origin-4"),
+		*newTestResult("shared-03", "shared", "origin-5"),
+	}
+	for i := range destinations {
+		destinations[i].Status = networkingv1.IngressStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{
+					{
+						IP: "10.1.1.1",
+					},
+				},
+			},
+		}
+	}
+
+	ingressBuckets := GenerateIngressBuckets(origins, destinations, 4)
+	require.Len(t, ingressBuckets, 3)
+
+	drained := CompactIngressBuckets(ingressBuckets, BucketCapacity{MaxSlots: 4})
+	require.Len(t, drained, 1)
+	assert.Equal(t, "shared-03", drained[0].DestinationIngress.Name)
+	assert.True(t, drained[0].Draining)
+	assert.Len(t, drained[0].Ingresses, 1)
+
+	// the fullest bucket the source fits into receives it
+	assert.Equal(t, "shared-01", ingressBuckets[2].DestinationIngress.Name)
+	assert.Len(t, ingressBuckets[2].Ingresses, 4)
+	assert.Equal(t, map[string]bool{"origin-5": true}, ingressBuckets[2].Incoming)
+
+	assert.Empty(t, CompactIngressBuckets(ingressBuckets, BucketCapacity{MaxSlots: 4}))
+	assert.Empty(t, CompactIngressBuckets(ingressBuckets, BucketCapacity{}))
+
+	// until its new bucket is written with it, the draining bucket keeps
+	// serving the source even though the new bucket has an address
+	destinations[2].Annotations = map[string]string{DrainingAnnotation: "true"}
+
+	ingressBuckets = GenerateIngressBuckets(origins, destinations, 4)
+	for _, bucket := range ingressBuckets {
+		switch bucket.DestinationIngress.Name {
+		case "shared-02":
+			assert.Equal(t, map[string]bool{"origin-5": true}, bucket.Incoming)
+		case "shared-03":
+			require.Len(t, bucket.Ingresses, 1)
+			assert.Equal(t, "origin-5", bucket.Ingresses[0].Name)
+		}
+	}
+
+	// once written, the source is owned by both buckets and released by the
+	// draining one because its new bucket has an address
+	destinations[0].OwnerReferences = append(destinations[0].OwnerReferences, destinations[2].OwnerReferences...)
+
+	ingressBuckets = GenerateIngressBuckets(origins, destinations, 4)
+	require.Len(t, ingressBuckets, 3)
+	for _, bucket := range ingressBuckets {
+		switch bucket.DestinationIngress.Name {
+		case "shared-01":
+			assert.Len(t, bucket.Ingresses, 4)
+			assert.Empty(t, bucket.Incoming)
+		case "shared-03":
+			assert.True(t, bucket.Draining)
+			assert.Empty(t, bucket.Ingresses)
+		}
+	}
+
+	// without an address the draining bucket keeps serving the source
+	destinations[0].Status = networkingv1.IngressStatus{}
+
+	ingressBuckets = GenerateIngressBuckets(origins, destinations, 4)
+	for _, bucket := range ingressBuckets {
+		switch bucket.DestinationIngress.Name {
+		case "shared-01":
+			assert.Equal(t, map[string]bool{"origin-5": true}, bucket.Incoming)
+		case "shared-03":
+			require.Len(t, bucket.Ingresses, 1)
+			assert.Equal(t, "origin-5", bucket.Ingresses[0].Name)
+		}
+	}
+}
+
+func TestGenerateIngressBucketsHostAffinity(t *testing.T) {
+	t.Run("new source joins the bucket serving its host", func(t *testing.T) {
+		origins := []networkingv1.Ingress{
+			newTestIngress("a", "x.example.org", numberedPaths("a", 1)...),
+			newTestIngress("b", "y.example.org", numberedPaths("b", 3)...),
+			newTestIngress("c", "x.example.org", numberedPaths("c", 1)...),
+		}
+		destinations := []networkingv1.Ingress{
+			*newTestResult("shared-01", "shared", "a"),
+			*newTestResult("shared-02", "shared", "b"),
+		}
+
+		// best fit would pick the fullest bucket, shared-02
+		ingressBuckets := GenerateIngressBucketsWithStrategy(origins, destinations, BucketCapacity{MaxSlots: 4}, BestFitDecreasingPacking{})
+		assert.Equal(t, map[string][]string{
+			"shared-01": {"a", "c"},
+			"shared-02": {"b"},
+		}, bucketNames(ingressBuckets))
+	})
+
+	t.Run("split host is brought together", func(t *testing.T) {
+		origins := []networkingv1.Ingress{
+			newTestIngress("a", "x.example.org", numberedPaths("a", 2)...),
+			newTestIngress("b", "y.example.org", numberedPaths("b", 1)...),
+			newTestIngress("c", "x.example.org", numberedPaths("c", 1)...),
+		}
+		destinations := []networkingv1.Ingress{
+			*newTestResult("shared-01", "shared", "a"),
+			*newTestResult("shared-02", "shared", "b", "c"),
+		}
+
+		ingressBuckets := GenerateIngressBuckets(origins, destinations, 4)
+		assert.Equal(t, map[string][]string{
+			"shared-01": {"a", "c"},
+			"shared-02": {"b"},
+		}, bucketNames(ingressBuckets))
+	})
+
+	t.Run("host moves to a bucket with room for all of it", func(t *testing.T) {
+		origins := []networkingv1.Ingress{
+			newTestIngress("a", "x.example.org", numberedPaths("a", 2)...),
+			newTestIngress("b", "y.example.org", numberedPaths("b", 2)...),
+			newTestIngress("c", "x.example.org", numberedPaths("c", 2)...),
+		}
+		destinations := []networkingv1.Ingress{
+			*newTestResult("shared-01", "shared", "a", "b"),
+		}
+
+		ingressBuckets := GenerateIngressBuckets(origins, destinations, 4)
+		assert.Equal(t, map[string][]string{
+			"shared-01": {"b"},
+			"":          {"a", "c"},
+		}, bucketNames(ingressBuckets))
+	})
+
+	t.Run("host needing more slots than a bucket is not split", func(t *testing.T) {
+		origins := []networkingv1.Ingress{
+			newTestIngress("a", "x.example.org", numberedPaths("a", 3)...),
+			newTestIngress("b", "y.example.org", numberedPaths("b", 1)...),
+			newTestIngress("c", "x.example.org", numberedPaths("c", 3)...),
+		}
+
+		ingressBuckets := GenerateIngressBuckets(origins, nil, 4)
+		require.Len(t, ingressBuckets, 2)
+		assert.Len(t, ingressBuckets[1].Ingresses, 2)
+		assert.Equal(t, -2, ingressBuckets[1].FreeSlots)
+
+		unschedulable := UnschedulableHostGroups(origins, BucketCapacity{MaxSlots: 4})
+		require.Len(t, unschedulable, 1)
+		assert.Len(t, unschedulable[0], 2)
+	})
+}
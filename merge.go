@@ -0,0 +1,201 @@
+package ingress_merge
+
+import (
+	"sort"
+	"strconv"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// MergePlan is the outcome of merging the source ingresses of a config,
+// computed without calling the API server.
+type MergePlan struct {
+	Capacity BucketCapacity
+	Buckets  []*IngressBucket
+	// Names holds the name of the result ingress of every bucket.
+	Names map[*IngressBucket]string
+	// Drained holds the buckets that started draining into the other ones.
+	Drained []*IngressBucket
+	// Unschedulable holds the groups of ingresses sharing hosts that need
+	// more than a result ingress has, they are merged together anyway.
+	Unschedulable [][]networkingv1.Ingress
+}
+
+// NameTaken tells whether a name is already taken by an ingress of the
+// namespace of a config.
+type NameTaken func(name string) (bool, error)
+
+// TakenNames returns a NameTaken looking names up in a set.
+func TakenNames(names map[string]bool) NameTaken {
+	return func(name string) (bool, error) {
+		return names[name], nil
+	}
+}
+
+// PlanMerge sorts the source ingresses of a config by priority, places them
+// into the buckets of the current result ingresses or new ones, and names
+// the result ingress of every bucket. Buckets are compacted when compact is
+// set. New result ingresses are not given names taken by the other ingresses
+// of the namespace, which are only checked for new buckets.
+func PlanMerge(config *MergeConfig, ingresses, currentResultIngresses []networkingv1.Ingress, nameTaken NameTaken, capacity BucketCapacity, compact bool) (*MergePlan, error) {
+	SortByPriority(ingresses)
+
+	plan := &MergePlan{
+		Capacity:      capacity,
+		Buckets:       GenerateIngressBucketsWithStrategy(ingresses, currentResultIngresses, capacity, config.PackingStrategy),
+		Names:         make(map[*IngressBucket]string),
+		Unschedulable: UnschedulableHostGroups(ingresses, capacity),
+	}
+
+	if compact {
+		plan.Drained = CompactIngressBuckets(plan.Buckets, capacity)
+	}
+
+	usedNames := make(map[string]bool)
+	for _, resultIngress := range currentResultIngresses {
+		usedNames[resultIngress.Name] = true
+	}
+
+	for _, bucket := range plan.Buckets {
+		if bucket.DestinationIngress != nil {
+			plan.Names[bucket] = bucket.DestinationIngress.Name
+			continue
+		}
+
+		name, err := nextResultIngressName(config.ResultName, usedNames, nameTaken)
+		if err != nil {
+			return nil, err
+		}
+		usedNames[name] = true
+		plan.Names[bucket] = name
+	}
+
+	return plan, nil
+}
+
+// SortByPriority sorts source ingresses by priority, highest first, then by
+// name. Their rules come in the result ingress in this order.
+func SortByPriority(ingresses []networkingv1.Ingress) {
+	sort.Slice(ingresses, func(i, j int) bool {
+		var (
+			a         = ingresses[i]
+			b         = ingresses[j]
+			priorityA = 0
+			priorityB = 0
+		)
+
+		if priorityString, exits := a.Annotations[PriorityAnnotation]; exits {
+			priorityA, _ = strconv.Atoi(priorityString)
+		}
+
+		if priorityString, exits := b.Annotations[PriorityAnnotation]; exits {
+			priorityB, _ = strconv.Atoi(priorityString)
+		}
+
+		if priorityA > priorityB {
+			return true
+		} else if priorityA < priorityB {
+			return false
+		} else {
+			return a.Name < b.Name
+		}
+	})
+}
+
+// BuildResultIngress builds the result ingress of a bucket, along with the
+// conflicting paths left out of it. The result ingress is owned by its source
+// ingresses, and by its config too when ownedByConfig is set, so the garbage
+// collector does not delete it as soon as its last source ingress is deleted,
+// and the controller can keep it for the grace period.
+func BuildResultIngress(config *MergeConfig, bucket *IngressBucket, name string, ownedByConfig bool) (*networkingv1.Ingress, []PathConflict) {
+	var (
+		ownerReferences []metaV1.OwnerReference
+		tls             []networkingv1.IngressTLS
+		rules           []networkingv1.IngressRule
+		wildcardDomains map[string]bool = make(map[string]bool)
+	)
+
+	if ownedByConfig {
+		ownerReferences = append(ownerReferences, config.ownerReference())
+	}
+
+	conflicts := ResolvePathConflicts(bucket.Ingresses)
+
+	for _, ingress := range bucket.Ingresses {
+		ownerReferences = append(ownerReferences, metaV1.OwnerReference{
+			APIVersion: ingress.APIVersion,
+			Kind:       "Ingress",
+			Name:       ingress.Name,
+			UID:        ingress.UID,
+		})
+
+	rules:
+		for _, r := range withoutConflictingPaths(&ingress, conflicts) {
+			for i := range rules {
+				if r.Host != rules[i].Host {
+					continue
+				}
+
+				// host only rules have no paths to merge
+				if r.HTTP != nil {
+					if rules[i].HTTP == nil {
+						rules[i].HTTP = &networkingv1.HTTPIngressRuleValue{}
+					}
+					rules[i].HTTP.Paths = append(rules[i].HTTP.Paths, r.HTTP.Paths...)
+				}
+				continue rules
+			}
+
+			rules = append(rules, *r.DeepCopy())
+		}
+
+		if config.UseWildcardTLS {
+			if config.UseWildcardTLSIgnore.Matches(labels.Set(ingress.Labels)) {
+				continue
+			}
+			wildcardDomains = mergeWildcardDomains(wildcardDomains, ingress.Spec.Rules)
+		} else {
+			tls = append(tls, ingress.Spec.TLS...)
+		}
+	}
+
+	if config.UseWildcardTLS {
+		tls = append(tls, wildcardTLSEntry(wildcardDomains, name))
+	}
+
+	annotations := make(map[string]string)
+	for k, v := range config.Annotations {
+		annotations[k] = v
+	}
+	annotations[FromConfigAnnotation] = config.Name()
+	annotations[ResultAnnotation] = "true"
+	if bucket.Draining {
+		annotations[DrainingAnnotation] = "true"
+	}
+
+	var ingressClassNameRef *string
+	if config.IngressClassName != "" {
+		ingressClassName := config.IngressClassName
+		ingressClassNameRef = &ingressClassName
+	}
+
+	mergedIngress := &networkingv1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:            name,
+			Namespace:       config.Namespace(),
+			Labels:          config.Labels,
+			Annotations:     annotations,
+			OwnerReferences: ownerReferences,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: ingressClassNameRef,
+			DefaultBackend:   config.DefaultBackend,
+			TLS:              tls,
+			Rules:            rules,
+		},
+	}
+
+	return mergedIngress, conflicts
+}
@@ -0,0 +1,161 @@
+package ingress_merge
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	mergev1alpha1 "github.com/tsuru/ingress-merge/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	createOperation            = "create"
+	updateOperation            = "update"
+	deleteOperation            = "delete"
+	statusPropagationOperation = "status_propagation"
+)
+
+var (
+	sourceIngressesMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ingress_merge_source_ingresses",
+		Help: "Number of source ingresses merged by a config.",
+	}, []string{"namespace", "config"})
+
+	resultIngressesMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ingress_merge_result_ingresses",
+		Help: "Number of result ingresses (buckets) of a config.",
+	}, []string{"namespace", "config"})
+
+	bucketUsedSlotsMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ingress_merge_bucket_used_slots",
+		Help: "Number of slots used in a result ingress.",
+	}, []string{"namespace", "config", "ingress"})
+
+	bucketMaxSlotsMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ingress_merge_bucket_max_slots",
+		Help: "Maximum number of slots of a result ingress.",
+	}, []string{"namespace", "config", "ingress"})
+
+	skippedIngressesMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ingress_merge_skipped_ingresses",
+		Help: "Number of source ingresses left out of the merge of a config, by reason.",
+	}, []string{"namespace", "config", "reason"})
+
+	operationsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ingress_merge_operations_total",
+		Help: "Number of writes made on ingresses, by operation.",
+	}, []string{"operation"})
+
+	operationErrorsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ingress_merge_operation_errors_total",
+		Help: "Number of failed writes on ingresses, by operation.",
+	}, []string{"operation"})
+
+	// bucketSeries holds the result ingresses with bucket series by namespace
+	// and config, so that the series of a deleted config can be deleted once
+	// its result ingresses are garbage collected.
+	bucketSeries      = make(map[types.NamespacedName]map[string]bool)
+	bucketSeriesMutex sync.Mutex
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		sourceIngressesMetric,
+		resultIngressesMetric,
+		bucketUsedSlotsMetric,
+		bucketMaxSlotsMetric,
+		skippedIngressesMetric,
+		operationsMetric,
+		operationErrorsMetric,
+	)
+}
+
+// skipReasons are the reasons a source ingress is left out of a merge for.
+var skipReasons = []string{
+	InvalidPriorityReason,
+	MissingConfigReason,
+	ConfigMapNotFoundReason,
+	ConfigNotWatchedReason,
+	IngressClassLoopReason,
+	IngressSelectorReason,
+}
+
+// setSkippedIngresses sets the number of source ingresses left out of the
+// merge of a config by reason, the source ingresses without config being
+// counted under an empty config. The series of the reasons without skipped
+// ingresses are deleted.
+func setSkippedIngresses(ns, configName string, skipped []mergev1alpha1.SkippedIngress) {
+	counts := make(map[string]int)
+	for _, ingress := range skipped {
+		counts[ingress.Reason]++
+	}
+
+	for _, reason := range skipReasons {
+		if counts[reason] == 0 {
+			skippedIngressesMetric.DeleteLabelValues(ns, configName, reason)
+			continue
+		}
+
+		skippedIngressesMetric.WithLabelValues(ns, configName, reason).Set(float64(counts[reason]))
+	}
+}
+
+// deleteMergeMetrics deletes the series of a config left without source
+// ingresses to merge.
+func deleteMergeMetrics(ns, configName string) {
+	sourceIngressesMetric.DeleteLabelValues(ns, configName)
+	resultIngressesMetric.DeleteLabelValues(ns, configName)
+}
+
+// setBucketSlots sets the used and maximum slots of a result ingress.
+func setBucketSlots(ns, configName, name string, usedSlots, maxSlots int) {
+	bucketSeriesMutex.Lock()
+	defer bucketSeriesMutex.Unlock()
+
+	key := types.NamespacedName{Namespace: ns, Name: configName}
+	if bucketSeries[key] == nil {
+		bucketSeries[key] = make(map[string]bool)
+	}
+	bucketSeries[key][name] = true
+
+	bucketUsedSlotsMetric.WithLabelValues(ns, configName, name).Set(float64(usedSlots))
+	bucketMaxSlotsMetric.WithLabelValues(ns, configName, name).Set(float64(maxSlots))
+}
+
+// deleteBucketSlots deletes the series of a result ingress.
+func deleteBucketSlots(ns, configName, name string) {
+	bucketSeriesMutex.Lock()
+	defer bucketSeriesMutex.Unlock()
+
+	key := types.NamespacedName{Namespace: ns, Name: configName}
+	delete(bucketSeries[key], name)
+	if len(bucketSeries[key]) == 0 {
+		delete(bucketSeries, key)
+	}
+
+	bucketUsedSlotsMetric.DeleteLabelValues(ns, configName, name)
+	bucketMaxSlotsMetric.DeleteLabelValues(ns, configName, name)
+}
+
+// deleteConfigBucketSlots deletes the series of all the result ingresses of
+// a config.
+func deleteConfigBucketSlots(ns, configName string) {
+	bucketSeriesMutex.Lock()
+	defer bucketSeriesMutex.Unlock()
+
+	key := types.NamespacedName{Namespace: ns, Name: configName}
+	for name := range bucketSeries[key] {
+		bucketUsedSlotsMetric.DeleteLabelValues(ns, configName, name)
+		bucketMaxSlotsMetric.DeleteLabelValues(ns, configName, name)
+	}
+	delete(bucketSeries, key)
+}
+
+// observeOperation counts a write on an ingress and whether it failed.
+func observeOperation(operation string, err error) {
+	operationsMetric.WithLabelValues(operation).Inc()
+	if err != nil {
+		operationErrorsMetric.WithLabelValues(operation).Inc()
+	}
+}
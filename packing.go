@@ -0,0 +1,280 @@
+package ingress_merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	FirstFitPackingName          = "first-fit"
+	BestFitDecreasingPackingName = "best-fit-decreasing"
+	LabelAffinityPackingName     = "label-affinity"
+)
+
+// PackingStrategy places the source ingresses not merged into a result
+// ingress yet, given as groups of ingresses sharing a host that must be
+// placed in the same bucket. The buckets of the existing result ingresses
+// are given with the most free slots first, the strategy must not add
+// ingresses to the draining ones. It returns the buckets it had to open.
+type PackingStrategy interface {
+	Pack(groups [][]networkingv1.Ingress, buckets []*IngressBucket, capacity BucketCapacity) []*IngressBucket
+}
+
+// FirstFitPacking fills the buckets one after the other with the smallest
+// groups first, the newest first among groups of the same size.
+type FirstFitPacking struct{}
+
+func (FirstFitPacking) Pack(groups [][]networkingv1.Ingress, buckets []*IngressBucket, capacity BucketCapacity) []*IngressBucket {
+	sort.Slice(groups, func(i, j int) bool {
+		slotsI := capacity.slots(groups[i])
+		slotsJ := capacity.slots(groups[j])
+
+		if slotsI != slotsJ {
+			return slotsI < slotsJ
+		}
+
+		return groupCreationTimestamp(groups[i]).After(groupCreationTimestamp(groups[j]).Time)
+	})
+
+	var currentBucket *IngressBucket
+	newBuckets := []*IngressBucket{}
+	reuseBucketsPos := -1
+
+	// nextBucket tells whether it had to open a new bucket
+	nextBucket := func() bool {
+		for reuseBucketsPos < len(buckets)-1 {
+			reuseBucketsPos++
+			currentBucket = buckets[reuseBucketsPos]
+
+			if currentBucket.FreeSlots > 0 && !currentBucket.Draining {
+				return false
+			}
+		}
+		currentBucket = capacity.newBucket()
+		newBuckets = append(newBuckets, currentBucket)
+
+		return true
+	}
+
+	if len(groups) > 0 {
+		nextBucket()
+	}
+
+	for _, group := range groups {
+		// a group not fitting into an empty bucket is placed alone
+		for !currentBucket.fits(group) {
+			if nextBucket() {
+				break
+			}
+		}
+
+		for _, ingress := range group {
+			currentBucket.add(ingress)
+		}
+	}
+
+	return newBuckets
+}
+
+// BestFitDecreasingPacking places the biggest groups first, each into the
+// fullest bucket it fits.
+type BestFitDecreasingPacking struct{}
+
+func (BestFitDecreasingPacking) Pack(groups [][]networkingv1.Ingress, buckets []*IngressBucket, capacity BucketCapacity) []*IngressBucket {
+	return packGroups(groups, buckets, capacity)
+}
+
+// LabelAffinityPacking prefers keeping the ingresses whose namespaces have
+// the same value of a label in the same bucket. The ingresses of a value are
+// split by host when they do not fit into one bucket, and the ingresses of
+// namespaces without the label are packed as best fit decreasing does.
+// NamespaceLabels holds the labels of the namespaces by name, they are looked
+// up before packing.
+type LabelAffinityPacking struct {
+	Label           string
+	NamespaceLabels map[string]map[string]string
+}
+
+func (p LabelAffinityPacking) Pack(groups [][]networkingv1.Ingress, buckets []*IngressBucket, capacity BucketCapacity) []*IngressBucket {
+	values := []string{}
+	valueGroups := make(map[string][][]networkingv1.Ingress)
+	unlabeled := [][]networkingv1.Ingress{}
+	for _, group := range groups {
+		value, exists := p.NamespaceLabels[group[0].Namespace][p.Label]
+		if !exists {
+			unlabeled = append(unlabeled, group)
+			continue
+		}
+
+		if _, seen := valueGroups[value]; !seen {
+			values = append(values, value)
+		}
+		valueGroups[value] = append(valueGroups[value], group)
+	}
+
+	joined := make(map[string][]networkingv1.Ingress)
+	for _, value := range values {
+		for _, group := range valueGroups[value] {
+			joined[value] = append(joined[value], group...)
+		}
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return capacity.slots(joined[values[i]]) > capacity.slots(joined[values[j]])
+	})
+
+	newBuckets := []*IngressBucket{}
+	place := func(groups [][]networkingv1.Ingress) {
+		candidates := append(append([]*IngressBucket{}, buckets...), newBuckets...)
+		newBuckets = append(newBuckets, packGroups(groups, candidates, capacity)...)
+	}
+
+	for _, value := range values {
+		if capacity.fits(joined[value]) {
+			place([][]networkingv1.Ingress{joined[value]})
+		} else {
+			place(valueGroups[value])
+		}
+	}
+	place(unlabeled)
+
+	return newBuckets
+}
+
+// ParsePackingStrategy returns the packing strategy of the given name, label
+// is only used by the label affinity strategy, whose namespace labels are
+// left to be looked up.
+func ParsePackingStrategy(name, label string) (PackingStrategy, error) {
+	switch name {
+	case "", FirstFitPackingName:
+		return FirstFitPacking{}, nil
+	case BestFitDecreasingPackingName:
+		return BestFitDecreasingPacking{}, nil
+	case LabelAffinityPackingName:
+		if label == "" {
+			return nil, fmt.Errorf("%s requires a label", LabelAffinityPackingName)
+		}
+
+		return LabelAffinityPacking{Label: label}, nil
+	}
+
+	return nil, fmt.Errorf("unknown packing strategy %q, must be one of %s", name, strings.Join([]string{
+		FirstFitPackingName,
+		BestFitDecreasingPackingName,
+		LabelAffinityPackingName,
+	}, ", "))
+}
+
+// groupIngresses groups together the ingresses sharing any of the keys
+// returned for them, keeping the order of their first ingress.
+func groupIngresses(ingresses []networkingv1.Ingress, keys func(*networkingv1.Ingress) []string) [][]networkingv1.Ingress {
+	parents := make([]int, len(ingresses))
+	for i := range parents {
+		parents[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+
+	keyOwners := make(map[string]int)
+	for i := range ingresses {
+		for _, key := range keys(&ingresses[i]) {
+			owner, exists := keyOwners[key]
+			if !exists {
+				keyOwners[key] = i
+				continue
+			}
+
+			if a, b := find(owner), find(i); a != b {
+				if a > b {
+					a, b = b, a
+				}
+				parents[b] = a
+			}
+		}
+	}
+
+	groups := [][]networkingv1.Ingress{}
+	groupIndexes := make(map[int]int)
+	for i := range ingresses {
+		root := find(i)
+		index, exists := groupIndexes[root]
+		if !exists {
+			index = len(groups)
+			groupIndexes[root] = index
+			groups = append(groups, nil)
+		}
+
+		groups[index] = append(groups[index], ingresses[i])
+	}
+
+	return groups
+}
+
+// packGroups places the groups of ingresses, biggest first, each into the
+// fullest bucket it fits, opening a new bucket when none does.
+func packGroups(groups [][]networkingv1.Ingress, buckets []*IngressBucket, capacity BucketCapacity) []*IngressBucket {
+	sort.SliceStable(groups, func(i, j int) bool {
+		return capacity.slots(groups[i]) > capacity.slots(groups[j])
+	})
+
+	newBuckets := []*IngressBucket{}
+
+	for _, group := range groups {
+		var target *IngressBucket
+		for _, candidates := range [][]*IngressBucket{buckets, newBuckets} {
+			for _, bucket := range candidates {
+				if bucket.Draining || !bucket.fits(group) {
+					continue
+				}
+
+				if target == nil || bucket.FreeSlots < target.FreeSlots {
+					target = bucket
+				}
+			}
+		}
+
+		if target == nil {
+			target = capacity.newBucket()
+			newBuckets = append(newBuckets, target)
+		}
+
+		for _, ingress := range group {
+			target.add(ingress)
+		}
+	}
+
+	return newBuckets
+}
+
+// groupCreationTimestamp returns the creation timestamp of the newest
+// ingress of the group.
+func groupCreationTimestamp(ingresses []networkingv1.Ingress) metaV1.Time {
+	newest := metaV1.Time{}
+	for i := range ingresses {
+		if ingresses[i].CreationTimestamp.After(newest.Time) {
+			newest = ingresses[i].CreationTimestamp
+		}
+	}
+
+	return newest
+}
+
+func ingressHosts(ingress *networkingv1.Ingress) []string {
+	hosts := []string{}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			hosts = append(hosts, rule.Host)
+		}
+	}
+
+	return hosts
+}
@@ -0,0 +1,156 @@
+package ingress_merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestPackingStrategies(t *testing.T) {
+	fragmenting := []networkingv1.Ingress{
+		newTestIngress("a", "a.example.org", numberedPaths("a", 1)...),
+		newTestIngress("b", "b.example.org", numberedPaths("b", 1)...),
+		newTestIngress("c", "c.example.org", numberedPaths("c", 3)...),
+		newTestIngress("d", "d.example.org", numberedPaths("d", 3)...),
+	}
+	sharedHost := []networkingv1.Ingress{
+		newTestIngress("a", "x.example.org", numberedPaths("a", 2)...),
+		newTestIngress("b", "y.example.org", numberedPaths("b", 1)...),
+		newTestIngress("c", "x.example.org", numberedPaths("c", 1)...),
+		newTestIngress("d", "z.example.org", numberedPaths("d", 2)...),
+	}
+	namespaceLabels := map[string]map[string]string{
+		"my-namespace": {"team": "red"},
+	}
+
+	tests := []struct {
+		name        string
+		ingresses   []networkingv1.Ingress
+		maxServices int
+		strategy    PackingStrategy
+		buckets     int
+		together    [][]string
+	}{
+		{
+			name:        "first fit leaves small ingresses alone",
+			ingresses:   fragmenting,
+			maxServices: 4,
+			strategy:    FirstFitPacking{},
+			buckets:     3,
+		},
+		{
+			name:        "best fit decreasing fills the gaps of big ingresses",
+			ingresses:   fragmenting,
+			maxServices: 4,
+			strategy:    BestFitDecreasingPacking{},
+			buckets:     2,
+		},
+		{
+			name:        "first fit keeps hosts together",
+			ingresses:   sharedHost,
+			maxServices: 3,
+			strategy:    FirstFitPacking{},
+			buckets:     2,
+			together:    [][]string{{"a", "c"}},
+		},
+		{
+			name:        "best fit decreasing keeps hosts together",
+			ingresses:   sharedHost,
+			maxServices: 3,
+			strategy:    BestFitDecreasingPacking{},
+			buckets:     2,
+			together:    [][]string{{"a", "c"}},
+		},
+		{
+			name:        "label affinity splits a label value not fitting into a bucket by host",
+			ingresses:   sharedHost,
+			maxServices: 3,
+			strategy:    LabelAffinityPacking{Label: "team", NamespaceLabels: namespaceLabels},
+			buckets:     2,
+			together:    [][]string{{"a", "c"}},
+		},
+		{
+			name:        "label affinity without the namespace label",
+			ingresses:   fragmenting,
+			maxServices: 4,
+			strategy:    LabelAffinityPacking{Label: "team"},
+			buckets:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origins := make([]networkingv1.Ingress, len(tt.ingresses))
+			copy(origins, tt.ingresses)
+
+			buckets := GenerateIngressBucketsWithStrategy(origins, nil, BucketCapacity{MaxSlots: tt.maxServices}, tt.strategy)
+			assert.Len(t, buckets, tt.buckets)
+
+			bucketOf := make(map[string]int)
+			for i, bucket := range buckets {
+				assert.GreaterOrEqual(t, bucket.FreeSlots, 0)
+				for _, ingress := range bucket.Ingresses {
+					bucketOf[ingress.Name] = i
+				}
+			}
+			assert.Len(t, bucketOf, len(tt.ingresses))
+
+			for _, names := range tt.together {
+				for _, name := range names[1:] {
+					assert.Equal(t, bucketOf[names[0]], bucketOf[name], "%s and %s are in different buckets", names[0], name)
+				}
+			}
+		})
+	}
+}
+
+func TestLabelAffinityPackingKeepsLabelValueTogether(t *testing.T) {
+	capacity := BucketCapacity{MaxSlots: 2}
+	newBuckets := func() []*IngressBucket {
+		buckets := []*IngressBucket{capacity.newBucket(), capacity.newBucket()}
+		buckets[0].add(newTestIngress("x", "x.example.org", numberedPaths("x", 1)...))
+		buckets[1].add(newTestIngress("y", "y.example.org", numberedPaths("y", 1)...))
+		return buckets
+	}
+	groups := func() [][]networkingv1.Ingress {
+		return [][]networkingv1.Ingress{
+			{newTestIngress("a", "a.example.org", numberedPaths("a", 1)...)},
+			{newTestIngress("b", "b.example.org", numberedPaths("b", 1)...)},
+		}
+	}
+
+	opened := BestFitDecreasingPacking{}.Pack(groups(), newBuckets(), capacity)
+	assert.Empty(t, opened)
+
+	strategy := LabelAffinityPacking{
+		Label: "team",
+		NamespaceLabels: map[string]map[string]string{
+			"my-namespace": {"team": "red"},
+		},
+	}
+	buckets := newBuckets()
+	opened = strategy.Pack(groups(), buckets, capacity)
+	require.Len(t, opened, 1)
+	assert.Equal(t, []string{"a", "b"}, bucketNames(opened)[""])
+	for _, bucket := range buckets {
+		assert.Len(t, bucket.Ingresses, 1)
+	}
+}
+
+func TestParsePackingStrategy(t *testing.T) {
+	strategy, err := ParsePackingStrategy("", "")
+	require.NoError(t, err)
+	assert.Equal(t, FirstFitPacking{}, strategy)
+
+	strategy, err = ParsePackingStrategy(LabelAffinityPackingName, "team")
+	require.NoError(t, err)
+	assert.Equal(t, LabelAffinityPacking{Label: "team"}, strategy)
+
+	_, err = ParsePackingStrategy(LabelAffinityPackingName, "")
+	assert.Error(t, err)
+
+	_, err = ParsePackingStrategy("worst-fit", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package ingress_merge
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// ResultAction is what a reconcile would do to a result ingress.
+type ResultAction string
+
+const (
+	CreateResultAction    ResultAction = "create"
+	UpdateResultAction    ResultAction = "update"
+	UnchangedResultAction ResultAction = "unchanged"
+	EmptyResultAction     ResultAction = "empty"
+)
+
+// ResultChange is what a reconcile would do to a result ingress. Current is
+// nil for a result ingress to create, Desired is nil for one left without
+// source ingresses, which is deleted.
+type ResultChange struct {
+	Action  ResultAction
+	Current *networkingv1.Ingress
+	Desired *networkingv1.Ingress
+}
+
+// Name returns the name of the result ingress.
+func (c ResultChange) Name() string {
+	if c.Desired != nil {
+		return c.Desired.Name
+	}
+
+	return c.Current.Name
+}
+
+// Diff describes the changes of the annotations, owner references, rules
+// and TLS of the result ingress, as a line diff of their YAML.
+func (c ResultChange) Diff() string {
+	current := &networkingv1.Ingress{}
+	if c.Current != nil {
+		current = c.Current
+	}
+
+	desired := &networkingv1.Ingress{}
+	if c.Desired != nil {
+		desired = c.Desired
+	}
+
+	var b strings.Builder
+	for _, field := range []struct {
+		name             string
+		current, desired interface{}
+	}{
+		{"annotations", current.Annotations, desired.Annotations},
+		{"ownerReferences", current.OwnerReferences, desired.OwnerReferences},
+		{"rules", current.Spec.Rules, desired.Spec.Rules},
+		{"tls", current.Spec.TLS, desired.Spec.TLS},
+	} {
+		currentLines := yamlLines(field.current)
+		desiredLines := yamlLines(field.desired)
+		if reflect.DeepEqual(currentLines, desiredLines) {
+			continue
+		}
+
+		b.WriteString(field.name + ":\n")
+		for _, line := range diffLines(currentLines, desiredLines) {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// Plan computes what a reconcile of the namespace would do to its result
+// ingresses, without writing anything. Source ingresses left out of a merge
+// are reported with events, like a reconcile does.
+func (r *IngressReconciler) Plan(ctx context.Context, ns string) ([]ResultChange, error) {
+	merges, err := r.listMerges(ctx, ns, "")
+	if err != nil {
+		return nil, err
+	}
+
+	configNames := []string{}
+	for configName := range merges.ingresses {
+		configNames = append(configNames, configName)
+	}
+	sort.Strings(configNames)
+
+	changes := []ResultChange{}
+
+	for _, configName := range configNames {
+		config := merges.configs[configName]
+		ingresses := merges.ingresses[configName]
+		if !r.checkConfig(config, ingresses) {
+			continue
+		}
+
+		plan, err := PlanMerge(config, ingresses, merges.resultIngressesOf(configName), r.nameTaken(ctx, ns), config.Capacity(r.IngressMaxSlots, r.SlotCounter), r.EnableBucketCompaction)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, bucket := range plan.Buckets {
+			if bucket.DestinationIngress != nil && len(bucket.Ingresses) == 0 {
+				changes = append(changes, ResultChange{
+					Action:  EmptyResultAction,
+					Current: bucket.DestinationIngress,
+				})
+				continue
+			}
+
+			desired, _ := BuildResultIngress(config, bucket, plan.Names[bucket], r.ResultIngressGracePeriod > 0)
+			change := ResultChange{
+				Action:  CreateResultAction,
+				Current: bucket.DestinationIngress,
+				Desired: desired,
+			}
+
+			if bucket.DestinationIngress != nil {
+				change.Action = UnchangedResultAction
+				if r.hasIngressChanged(bucket.DestinationIngress, desired) {
+					change.Action = UpdateResultAction
+				}
+			}
+
+			changes = append(changes, change)
+		}
+	}
+
+	for i := range merges.resultIngresses {
+		resultIngress := &merges.resultIngresses[i]
+		if _, exists := merges.ingresses[resultIngress.Annotations[FromConfigAnnotation]]; exists {
+			continue
+		}
+
+		orphan, err := r.isOrphanResultIngress(ctx, resultIngress)
+		if err != nil {
+			return nil, err
+		}
+
+		if orphan {
+			changes = append(changes, ResultChange{
+				Action:  EmptyResultAction,
+				Current: resultIngress,
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+func yamlLines(v interface{}) []string {
+	if value := reflect.ValueOf(v); value.Len() == 0 {
+		return nil
+	}
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+// diffLines returns the lines of a and b prefixed by "- " when removed, "+ "
+// when added and "  " when kept, following their longest common subsequence.
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := []string{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		lines = append(lines, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+ "+b[j])
+	}
+
+	return lines
+}
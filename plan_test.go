@@ -0,0 +1,59 @@
+package ingress_merge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPlan(t *testing.T) {
+	ctx := context.Background()
+
+	current, _ := BuildResultIngress(&MergeConfig{
+		Object: newTestConfigMap("shared", nil),
+		Kind:   "ConfigMap",
+	}, &IngressBucket{Ingresses: []networkingv1.Ingress{*newTestSource("a", "shared")}}, "shared", false)
+
+	reconciler := newTestReconciler([]runtime.Object{
+		newTestSource("a", "shared"),
+		newTestSource("b", "shared"),
+		current,
+		newTestConfigMap("shared", nil),
+		newTestResult("gone", "gone"),
+	})
+
+	changes, err := reconciler.Plan(ctx, "my-namespace")
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	assert.Equal(t, UpdateResultAction, changes[0].Action)
+	assert.Equal(t, "shared", changes[0].Name())
+	assert.Contains(t, changes[0].Diff(), "+   name: b\n")
+	assert.Contains(t, changes[0].Diff(), "+ - host: b.example.org\n")
+
+	assert.Equal(t, EmptyResultAction, changes[1].Action)
+	assert.Equal(t, "gone", changes[1].Name())
+
+	// nothing is written
+	ingresses := &networkingv1.IngressList{}
+	require.NoError(t, reconciler.Client.List(ctx, ingresses))
+	assert.Len(t, ingresses.Items, 4)
+
+	require.NoError(t, reconciler.Client.Get(ctx, client.ObjectKeyFromObject(current), current))
+	assert.Len(t, current.Spec.Rules, 1)
+}
+
+func TestDiffLines(t *testing.T) {
+	assert.Equal(t, []string{
+		"  a",
+		"- b",
+		"+ x",
+		"  c",
+		"+ d",
+	}, diffLines([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"}))
+}
@@ -0,0 +1,20 @@
+package ingress_merge
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+)
+
+// NewRateLimiter paces the requeues of merge groups like the default
+// controller-runtime rate limiter: a failing group is retried after an
+// exponential backoff between baseDelay and maxDelay, and requeues overall
+// are limited to qps with bursts of burst.
+func NewRateLimiter(baseDelay, maxDelay time.Duration, qps float64, burst int) ratelimiter.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(qps), burst)},
+	)
+}
@@ -0,0 +1,27 @@
+package ingress_merge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(10*time.Millisecond, 30*time.Millisecond, 1000, 1000)
+
+	assert.Equal(t, 10*time.Millisecond, limiter.When("a"))
+	assert.Equal(t, 20*time.Millisecond, limiter.When("a"))
+	assert.Equal(t, 30*time.Millisecond, limiter.When("a"))
+	assert.Equal(t, 30*time.Millisecond, limiter.When("a"))
+	assert.Equal(t, 10*time.Millisecond, limiter.When("b"))
+	assert.Equal(t, 4, limiter.NumRequeues("a"))
+
+	limiter.Forget("a")
+	assert.Equal(t, 10*time.Millisecond, limiter.When("a"))
+
+	// the token bucket delays requeues once its burst is used
+	limiter = NewRateLimiter(time.Millisecond, time.Millisecond, 1, 1)
+	assert.Equal(t, time.Millisecond, limiter.When("a"))
+	assert.Greater(t, int64(limiter.When("b")), int64(900*time.Millisecond))
+}
@@ -0,0 +1,152 @@
+package ingress_merge
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	mergev1alpha1 "github.com/tsuru/ingress-merge/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// RenderOptions are the controller settings a render is made with.
+type RenderOptions struct {
+	IngressClass           string
+	IngressMaxSlots        int
+	SlotCounter            SlotCounter
+	EnableBucketCompaction bool
+}
+
+// Render merges source ingresses the way the controller does, without a
+// cluster, and returns the result ingresses. The config of a source ingress
+// is looked up among the given ConfigMaps and IngressMerges of its
+// namespace, an IngressMerge taking precedence over a ConfigMap. The labels
+// of namespaces used by the label affinity packing strategy are looked up
+// among the given Namespaces. Result ingresses among the given ingresses are
+// taken as the current ones. What the controller would report as warning
+// events is returned as warnings.
+func Render(ingresses []networkingv1.Ingress, configMaps []corev1.ConfigMap, ingressMerges []mergev1alpha1.IngressMerge, namespaces []corev1.Namespace, opts RenderOptions) ([]networkingv1.Ingress, []error) {
+	configs := make(map[string]*MergeConfig)
+	for i := range configMaps {
+		configs[configMaps[i].Namespace+"/"+configMaps[i].Name] = ConfigFromConfigMap(&configMaps[i])
+	}
+	for i := range ingressMerges {
+		configs[ingressMerges[i].Namespace+"/"+ingressMerges[i].Name] = ConfigFromIngressMerge(&ingressMerges[i])
+	}
+
+	namespaceLabels := make(map[string]map[string]string)
+	for _, namespace := range namespaces {
+		namespaceLabels[namespace.Name] = namespace.Labels
+	}
+	for _, config := range configs {
+		if strategy, ok := config.PackingStrategy.(LabelAffinityPacking); ok {
+			strategy.NamespaceLabels = namespaceLabels
+			config.PackingStrategy = strategy
+		}
+	}
+
+	var (
+		warnings        []error
+		mergeMap        = make(map[string][]networkingv1.Ingress)
+		resultIngresses = make(map[string][]networkingv1.Ingress)
+		names           = make(map[string]map[string]bool)
+	)
+
+	for _, ingress := range ingresses {
+		if names[ingress.Namespace] == nil {
+			names[ingress.Namespace] = make(map[string]bool)
+		}
+		names[ingress.Namespace][ingress.Name] = true
+
+		if ingress.Annotations[ResultAnnotation] == "true" {
+			key := ingress.Namespace + "/" + ingress.Annotations[FromConfigAnnotation]
+			resultIngresses[key] = append(resultIngresses[key], ingress)
+			continue
+		}
+
+		if getIngressClass(&ingress) != opts.IngressClass {
+			continue
+		}
+
+		if priorityString, exists := ingress.Annotations[PriorityAnnotation]; exists {
+			if _, err := strconv.Atoi(priorityString); err != nil {
+				warnings = append(warnings, fmt.Errorf("ingress %s: annotation %s must be an integer, got %q", ingress.Name, PriorityAnnotation, priorityString))
+				continue
+			}
+		}
+
+		configName, exists := ingress.Annotations[ConfigAnnotation]
+		if !exists {
+			warnings = append(warnings, fmt.Errorf("ingress %s: annotation %s is missing", ingress.Name, ConfigAnnotation))
+			continue
+		}
+
+		key := ingress.Namespace + "/" + configName
+		config, exists := configs[key]
+		if !exists {
+			warnings = append(warnings, fmt.Errorf("ingress %s: config %s referenced by annotation %s is not found", ingress.Name, configName, ConfigAnnotation))
+			continue
+		}
+
+		if !config.IngressSelector.Matches(labels.Set(ingress.Labels)) {
+			continue
+		}
+
+		mergeMap[key] = append(mergeMap[key], ingress)
+	}
+
+	keys := []string{}
+	for key := range mergeMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	results := []networkingv1.Ingress{}
+
+	for _, key := range keys {
+		config := configs[key]
+		for _, err := range config.Errors {
+			warnings = append(warnings, fmt.Errorf("invalid %s %s: %w", strings.ToLower(config.Kind), config.Name(), err))
+		}
+
+		if config.LoopsInto(opts.IngressClass) {
+			warnings = append(warnings, fmt.Errorf("%s %s sets the result ingress class to %s, which is the merge ingress class", strings.ToLower(config.Kind), config.Name(), opts.IngressClass))
+			continue
+		}
+
+		// names are looked up in a set, which never fails
+		plan, _ := PlanMerge(config, mergeMap[key], resultIngresses[key], TakenNames(names[config.Namespace()]), config.Capacity(opts.IngressMaxSlots, opts.SlotCounter), opts.EnableBucketCompaction)
+
+		for _, group := range plan.Unschedulable {
+			names := []string{}
+			for _, ingress := range group {
+				names = append(names, ingress.Name)
+			}
+
+			warnings = append(warnings, fmt.Errorf("ingresses %s share hosts and need more than a result ingress has together: %s",
+				strings.Join(names, ", "), strings.Join(plan.Capacity.exceeded(group), ", ")))
+		}
+
+		for _, bucket := range plan.Buckets {
+			// the controller deletes the result ingresses left empty
+			if len(bucket.Ingresses) == 0 {
+				continue
+			}
+
+			resultIngress, conflicts := BuildResultIngress(config, bucket, plan.Names[bucket], false)
+			for _, conflict := range conflicts {
+				warnings = append(warnings, fmt.Errorf("ingress %s: path %s%s (%s) is also declared by ingress %s, which takes precedence",
+					conflict.Ingress.Name, conflict.Host, conflict.Path, conflict.PathType, conflict.Winner.Name))
+			}
+
+			resultIngress.APIVersion = networkingv1.SchemeGroupVersion.String()
+			resultIngress.Kind = "Ingress"
+			results = append(results, *resultIngress)
+		}
+	}
+
+	return results, warnings
+}
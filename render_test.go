@@ -0,0 +1,93 @@
+package ingress_merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestRender(t *testing.T) {
+	missingConfig := *newTestSource("missing-config", "")
+	delete(missingConfig.Annotations, ConfigAnnotation)
+
+	ingresses := []networkingv1.Ingress{
+		*newTestSource("a", "shared"),
+		withAnnotations(*newTestSource("b", "shared"), map[string]string{PriorityAnnotation: "10"}),
+		*newTestSource("c", "shared"),
+		missingConfig,
+		*newTestSource("unknown-config", "unknown"),
+	}
+	configMaps := []corev1.ConfigMap{
+		*newTestConfigMap("shared", map[string]string{
+			NameConfigKey:     "shared-ingress",
+			MaxSlotsConfigKey: "2",
+		}),
+	}
+
+	resultIngresses, warnings := Render(ingresses, configMaps, nil, nil, RenderOptions{
+		IngressClass:    "merge",
+		IngressMaxSlots: 45,
+	})
+	assert.Len(t, warnings, 2)
+	require.Len(t, resultIngresses, 2)
+
+	hosts := [][]string{}
+	for _, resultIngress := range resultIngresses {
+		assert.Equal(t, "networking.k8s.io/v1", resultIngress.APIVersion)
+		assert.Equal(t, "Ingress", resultIngress.Kind)
+		assert.Equal(t, "my-namespace", resultIngress.Namespace)
+		assert.Equal(t, "shared", resultIngress.Annotations[FromConfigAnnotation])
+
+		ruleHosts := []string{}
+		for _, rule := range resultIngress.Spec.Rules {
+			ruleHosts = append(ruleHosts, rule.Host)
+		}
+		hosts = append(hosts, ruleHosts)
+	}
+
+	assert.Equal(t, "shared-ingress", resultIngresses[0].Name)
+	assert.Equal(t, "shared-ingress-1", resultIngresses[1].Name)
+	assert.ElementsMatch(t, []string{"a.example.org", "b.example.org", "c.example.org"}, append(hosts[0], hosts[1]...))
+}
+
+func TestRenderHostOnlyRules(t *testing.T) {
+	// a host only rule before and after a rule with paths of the same host
+	hostOnly := *newTestSource("host-only", "shared")
+	hostOnly.Spec.Rules = append(hostOnly.Spec.Rules, networkingv1.IngressRule{Host: "shared.example.org"})
+	hostOnly.Spec.Rules[0].HTTP = nil
+	withPaths := withAnnotations(newTestIngress("with-paths", "shared.example.org", "/"), map[string]string{
+		IngressClassAnnotation: "merge",
+		ConfigAnnotation:       "shared",
+	})
+	hostOnlyAfter := *newTestSource("host-only-after", "shared")
+	hostOnlyAfter.Spec.Rules[0].Host = "shared.example.org"
+	hostOnlyAfter.Spec.Rules[0].HTTP = nil
+
+	resultIngresses, warnings := Render(
+		[]networkingv1.Ingress{hostOnly, withPaths, hostOnlyAfter},
+		[]corev1.ConfigMap{*newTestConfigMap("shared", nil)},
+		nil,
+		nil,
+		RenderOptions{IngressClass: "merge", IngressMaxSlots: 45},
+	)
+	assert.Len(t, warnings, 0)
+	require.Len(t, resultIngresses, 1)
+
+	paths := map[string][]string{}
+	for _, rule := range resultIngresses[0].Spec.Rules {
+		paths[rule.Host] = []string{}
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			paths[rule.Host] = append(paths[rule.Host], path.Path)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"host-only.example.org": {},
+		"shared.example.org":    {"/"},
+	}, paths)
+}
@@ -0,0 +1,91 @@
+package ingress_merge
+
+import (
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// groupSettler holds merge groups back while their objects keep changing, so
+// a burst of changes, e.g. a release rolling out many ingresses at once, is
+// merged into a single update of the result ingresses. A group is merged once
+// no event came for the settle window, or once it has waited for the max
+// delay since the first event of the burst. A nil groupSettler never holds
+// groups back.
+type groupSettler struct {
+	window   time.Duration
+	maxDelay time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	bursts map[reconcile.Request]burst
+}
+
+// burst is the time of the first and last events of a merge group not merged
+// yet.
+type burst struct {
+	first, last time.Time
+}
+
+func newGroupSettler(window, maxDelay time.Duration) *groupSettler {
+	if window <= 0 {
+		return nil
+	}
+
+	return &groupSettler{
+		window:   window,
+		maxDelay: maxDelay,
+		now:      time.Now,
+		bursts:   make(map[reconcile.Request]burst),
+	}
+}
+
+// observe records an event of a merge group.
+func (s *groupSettler) observe(req reconcile.Request) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, exists := s.bursts[req]
+	if !exists {
+		b.first = now
+	}
+	b.last = now
+	s.bursts[req] = b
+}
+
+// wait returns how long a merge group should still be held back, the group
+// being forgotten once it can be merged.
+func (s *groupSettler) wait(req reconcile.Request) time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, exists := s.bursts[req]
+	if !exists {
+		return 0
+	}
+
+	now := s.now()
+	wait := b.last.Add(s.window).Sub(now)
+	if s.maxDelay > 0 {
+		if capped := b.first.Add(s.maxDelay).Sub(now); capped < wait {
+			wait = capped
+		}
+	}
+
+	if wait <= 0 {
+		delete(s.bursts, req)
+		return 0
+	}
+
+	return wait
+}
@@ -0,0 +1,51 @@
+package ingress_merge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupSettler(t *testing.T) {
+	now := time.Date(2021, 8, 1, 10, 0, 0, 0, time.UTC)
+	settler := newGroupSettler(10*time.Second, 30*time.Second)
+	settler.now = func() time.Time { return now }
+
+	group := mergeGroupRequest("my-namespace", "shared")
+	other := mergeGroupRequest("my-namespace", "other")
+
+	assert.Equal(t, time.Duration(0), settler.wait(group))
+
+	settler.observe(group)
+	assert.Equal(t, 10*time.Second, settler.wait(group))
+	assert.Equal(t, time.Duration(0), settler.wait(other))
+
+	// every event restarts the settle window
+	now = now.Add(8 * time.Second)
+	settler.observe(group)
+	assert.Equal(t, 10*time.Second, settler.wait(group))
+
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, time.Duration(0), settler.wait(group))
+	assert.Equal(t, time.Duration(0), settler.wait(group))
+
+	// a burst is held back for the max delay at most
+	for i := 0; i < 4; i++ {
+		settler.observe(group)
+		now = now.Add(7 * time.Second)
+	}
+	assert.Equal(t, 2*time.Second, settler.wait(group))
+
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, time.Duration(0), settler.wait(group))
+}
+
+func TestGroupSettlerDisabled(t *testing.T) {
+	settler := newGroupSettler(0, 30*time.Second)
+	assert.Nil(t, settler)
+
+	group := mergeGroupRequest("my-namespace", "shared")
+	settler.observe(group)
+	assert.Equal(t, time.Duration(0), settler.wait(group))
+}
@@ -0,0 +1,133 @@
+package ingress_merge
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+const (
+	GCESlotCounterName       = "gce"
+	ALBSlotCounterName       = "aws-alb"
+	UnlimitedSlotCounterName = "unlimited"
+
+	// ALBListenPortsAnnotation and ALBSSLRedirectAnnotation configure the
+	// listeners of the load balancer of an ingress for the AWS load balancer
+	// controller.
+	ALBListenPortsAnnotation = "alb.ingress.kubernetes.io/listen-ports"
+	ALBSSLRedirectAnnotation = "alb.ingress.kubernetes.io/ssl-redirect"
+)
+
+// SlotCounter tells how many slots of a result ingress a source ingress
+// uses, following the limits enforced by the ingress provider.
+type SlotCounter interface {
+	Slots(ingress *networkingv1.Ingress) int
+}
+
+// GCESlotCounter counts the path rules of a GCE URL map, one per path and at
+// least one per host.
+type GCESlotCounter struct{}
+
+func (GCESlotCounter) Slots(ingress *networkingv1.Ingress) int {
+	return ingressSlots(ingress)
+}
+
+// ALBSlotCounter counts the listener rules of an AWS application load
+// balancer the way the AWS load balancer controller creates them: one rule
+// per path on every listener, whose conditions match the host and the path,
+// while hosts without paths get none. The rule quota applies to the whole
+// load balancer, so the rules of a path are counted once per listener, one
+// listener when Listeners is not set. Target groups and certificates are
+// limited separately, per distinct backend service port and TLS secret of a
+// result ingress, see Limits.
+type ALBSlotCounter struct {
+	Listeners int
+}
+
+func (c ALBSlotCounter) Slots(ingress *networkingv1.Ingress) int {
+	paths := 0
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP != nil {
+			paths += len(rule.HTTP.Paths)
+		}
+	}
+
+	if c.Listeners > 1 {
+		return paths * c.Listeners
+	}
+
+	return paths
+}
+
+// albListeners returns the number of listeners holding the rules of a
+// result ingress with the given annotations. Without listen ports the load
+// balancer has a single listener, and only HTTPS listeners hold rules when
+// HTTP is redirected to HTTPS.
+func albListeners(annotations map[string]string) int {
+	listenPorts := []map[string]int{}
+	err := json.Unmarshal([]byte(annotations[ALBListenPortsAnnotation]), &listenPorts)
+	if err != nil || len(listenPorts) == 0 {
+		return 1
+	}
+
+	_, sslRedirect := annotations[ALBSSLRedirectAnnotation]
+
+	listeners := 0
+	for _, listenPort := range listenPorts {
+		for protocol := range listenPort {
+			if protocol == "HTTPS" || !sslRedirect {
+				listeners++
+			}
+		}
+	}
+
+	if listeners == 0 {
+		return 1
+	}
+
+	return listeners
+}
+
+// Limits returns the default quotas of target groups and certificates of an
+// application load balancer.
+func (ALBSlotCounter) Limits() BucketLimits {
+	return BucketLimits{
+		MaxCertificates: 25,
+		MaxBackends:     100,
+	}
+}
+
+// LimitedSlotCounter is a SlotCounter of a provider limiting other resources
+// of a result ingress besides the slots. Its limits apply unless configured.
+type LimitedSlotCounter interface {
+	SlotCounter
+	Limits() BucketLimits
+}
+
+// UnlimitedSlotCounter is used for providers without limits, all the source
+// ingresses are merged into a single result ingress.
+type UnlimitedSlotCounter struct{}
+
+func (UnlimitedSlotCounter) Slots(ingress *networkingv1.Ingress) int {
+	return 0
+}
+
+// ParseSlotCounter returns the slot counter of the given profile name.
+func ParseSlotCounter(name string) (SlotCounter, error) {
+	switch name {
+	case GCESlotCounterName:
+		return GCESlotCounter{}, nil
+	case ALBSlotCounterName:
+		return ALBSlotCounter{}, nil
+	case UnlimitedSlotCounterName:
+		return UnlimitedSlotCounter{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown slot counter %q, must be one of %s", name, strings.Join([]string{
+		GCESlotCounterName,
+		ALBSlotCounterName,
+		UnlimitedSlotCounterName,
+	}, ", "))
+}
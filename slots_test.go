@@ -0,0 +1,123 @@
+package ingress_merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestSlotCounters(t *testing.T) {
+	withBackends := newTestIngress("a", "a.example.org", numberedPaths("a", 3)...)
+	for i, service := range []string{"svc-1", "svc-2", "svc-1"} {
+		withBackends.Spec.Rules[0].HTTP.Paths[i].Backend = networkingv1.IngressBackend{
+			Service: &networkingv1.IngressServiceBackend{Name: service},
+		}
+	}
+	withBackends.Spec.TLS = []networkingv1.IngressTLS{
+		{Hosts: []string{"a.example.org"}, SecretName: "a-tls"},
+		{Hosts: []string{"www.a.example.org"}, SecretName: "a-tls"},
+	}
+
+	noPaths := newTestIngress("b", "b.example.org")
+
+	tests := []struct {
+		name    string
+		counter SlotCounter
+		ingress networkingv1.Ingress
+		slots   int
+	}{
+		{"gce counts paths", GCESlotCounter{}, withBackends, 3},
+		{"gce counts hosts without paths", GCESlotCounter{}, noPaths, 1},
+		{"alb counts paths", ALBSlotCounter{}, withBackends, 3},
+		{"alb counts the paths of every listener", ALBSlotCounter{Listeners: 2}, withBackends, 6},
+		{"alb ignores hosts without paths", ALBSlotCounter{}, noPaths, 0},
+		{"unlimited counts nothing", UnlimitedSlotCounter{}, withBackends, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.slots, tt.counter.Slots(&tt.ingress))
+		})
+	}
+}
+
+func TestGenerateIngressBucketsSlotCounter(t *testing.T) {
+	origins := []networkingv1.Ingress{
+		newTestIngress("a", "a.example.org", numberedPaths("a", 2)...),
+		newTestIngress("b", "b.example.org", numberedPaths("b", 2)...),
+		newTestIngress("c", "c.example.org", numberedPaths("c", 2)...),
+	}
+
+	buckets := GenerateIngressBucketsWithStrategy(origins, nil, BucketCapacity{MaxSlots: 6}, FirstFitPacking{})
+	assert.Len(t, buckets, 1)
+
+	buckets = GenerateIngressBucketsWithStrategy(origins, nil, BucketCapacity{MaxSlots: 1, SlotCounter: UnlimitedSlotCounter{}}, FirstFitPacking{})
+	require.Len(t, buckets, 1)
+	assert.Len(t, buckets[0].Ingresses, 3)
+}
+
+func TestGenerateIngressBucketsALBLimits(t *testing.T) {
+	// the sources share a certificate and a target group
+	shared := []networkingv1.Ingress{
+		withTLS(newTestIngress("a", "a.example.org", "/"), "shared-tls"),
+		withTLS(newTestIngress("b", "b.example.org", "/"), "shared-tls"),
+		withTLS(newTestIngress("c", "c.example.org", "/"), "shared-tls"),
+	}
+	for i := range shared {
+		shared[i].Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name = "shared"
+	}
+
+	config := &MergeConfig{Limits: BucketLimits{MaxBackends: 2}}
+	capacity := config.Capacity(45, ALBSlotCounter{})
+	assert.Equal(t, BucketLimits{MaxCertificates: 25, MaxBackends: 2}, capacity.Limits)
+
+	buckets := GenerateIngressBucketsWithStrategy(shared, nil, capacity, FirstFitPacking{})
+	assert.Len(t, buckets, 1)
+
+	// target groups are counted per service port
+	for i := range shared {
+		shared[i].Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port.Number = int32(8080 + i)
+	}
+
+	buckets = GenerateIngressBucketsWithStrategy(shared, nil, capacity, FirstFitPacking{})
+	assert.Len(t, buckets, 2)
+}
+
+func TestALBListeners(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		listeners   int
+	}{
+		{"default listener", nil, 1},
+		{"listen ports", map[string]string{
+			ALBListenPortsAnnotation: `[{"HTTP": 80}, {"HTTPS": 443}]`,
+		}, 2},
+		{"http redirected to https", map[string]string{
+			ALBListenPortsAnnotation: `[{"HTTP": 80}, {"HTTPS": 443}]`,
+			ALBSSLRedirectAnnotation: "443",
+		}, 1},
+		{"invalid listen ports", map[string]string{
+			ALBListenPortsAnnotation: `HTTP:80`,
+		}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &MergeConfig{Annotations: tt.annotations}
+			capacity := config.Capacity(100, ALBSlotCounter{})
+			assert.Equal(t, ALBSlotCounter{Listeners: tt.listeners}, capacity.SlotCounter)
+		})
+	}
+}
+
+func TestParseSlotCounter(t *testing.T) {
+	counter, err := ParseSlotCounter(ALBSlotCounterName)
+	require.NoError(t, err)
+	assert.Equal(t, ALBSlotCounter{}, counter)
+
+	_, err = ParseSlotCounter("azure")
+	assert.Error(t, err)
+}
@@ -0,0 +1,228 @@
+package ingress_merge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	ValidateIngressPath   = "/validate-networking-v1-ingress"
+	ValidateConfigMapPath = "/validate-v1-configmap"
+)
+
+// Validator rejects merge-class ingresses and merge configmaps that would
+// only fail at reconcile time. Its ingress class, selector and ignored
+// annotations are the ones of the controller, so only the ingresses it
+// merges are validated.
+type Validator struct {
+	client.Client
+
+	IngressClass       string
+	IngressSelector    labels.Selector
+	IngressWatchIgnore []string
+	// Indexed tells whether the ingress indexes of the controller are
+	// registered on the cache the client reads from.
+	Indexed bool
+
+	decoder *admission.Decoder
+}
+
+func (v *Validator) SetupWithManager(mgr ctrl.Manager) error {
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	v.decoder = decoder
+
+	server := mgr.GetWebhookServer()
+	server.Register(ValidateIngressPath, &webhook.Admission{Handler: admission.HandlerFunc(v.validateIngress)})
+	server.Register(ValidateConfigMapPath, &webhook.Admission{Handler: admission.HandlerFunc(v.validateConfigMap)})
+
+	return nil
+}
+
+func (v *Validator) validateIngress(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1.Delete {
+		return admission.Allowed("")
+	}
+
+	ingress := &networkingv1.Ingress{}
+	if err := v.decoder.Decode(req, ingress); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if !v.isSource(ingress) {
+		return admission.Allowed("")
+	}
+
+	// ingresses being deleted, e.g. their finalizers being removed, are not
+	// merged anymore
+	if ingress.DeletionTimestamp != nil {
+		return admission.Allowed("")
+	}
+
+	// updates leaving the rules and merge annotations alone, e.g. labels and
+	// annotations set by other controllers, do not change the merge, even an
+	// invalid one
+	if req.Operation == admissionv1.Update {
+		oldIngress := &networkingv1.Ingress{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldIngress); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		if !isMergeChanged(oldIngress, ingress) {
+			return admission.Allowed("")
+		}
+	}
+
+	if priorityString, exists := ingress.Annotations[PriorityAnnotation]; exists {
+		if _, err := strconv.Atoi(priorityString); err != nil {
+			return admission.Denied(fmt.Sprintf("annotation %s must be an integer, got %q", PriorityAnnotation, priorityString))
+		}
+	}
+
+	configName, exists := ingress.Annotations[ConfigAnnotation]
+	if !exists {
+		return admission.Denied(fmt.Sprintf("annotation %s is missing", ConfigAnnotation))
+	}
+
+	// new ingresses are younger than any sibling
+	if ingress.CreationTimestamp.IsZero() {
+		ingress.CreationTimestamp = metaV1.Now()
+	}
+
+	siblings, err := v.listSources(ctx, ingress.Namespace, configName)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	for _, sibling := range siblings {
+		if sibling.Name == ingress.Name {
+			continue
+		}
+
+		for _, conflict := range ResolvePathConflicts([]networkingv1.Ingress{sibling, *ingress}) {
+			if conflict.Ingress.Name != ingress.Name {
+				continue
+			}
+
+			return admission.Denied(fmt.Sprintf("path %s%s (%s) is already declared by ingress %s, which takes precedence",
+				conflict.Host, conflict.Path, conflict.PathType, conflict.Winner.Name))
+		}
+	}
+
+	return admission.Allowed("")
+}
+
+func (v *Validator) validateConfigMap(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1.Delete {
+		return admission.Allowed("")
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := v.decoder.Decode(req, configMap); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// updates leaving the data alone, e.g. the status annotation written by
+	// the controller, do not change the config, even an invalid one
+	if req.Operation == admissionv1.Update {
+		oldConfigMap := &corev1.ConfigMap{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldConfigMap); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		if reflect.DeepEqual(oldConfigMap.Data, configMap.Data) {
+			return admission.Allowed("")
+		}
+	}
+
+	sources, err := v.listSources(ctx, configMap.Namespace, configMap.Name)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	// only configmaps referenced by merge-class ingresses are merge configmaps
+	if len(sources) == 0 {
+		return admission.Allowed("")
+	}
+
+	config := ConfigFromConfigMap(configMap)
+	if len(config.Errors) > 0 {
+		messages := []string{}
+		for _, err := range config.Errors {
+			messages = append(messages, err.Error())
+		}
+		return admission.Denied(strings.Join(messages, "; "))
+	}
+
+	if config.LoopsInto(v.IngressClass) {
+		return admission.Denied(fmt.Sprintf("result ingress class must not be the merge ingress class %s", v.IngressClass))
+	}
+
+	return admission.Allowed("")
+}
+
+// isMergeChanged tells whether an update of a source ingress changes what
+// is validated, its merge annotations or its rules.
+func isMergeChanged(old, new *networkingv1.Ingress) bool {
+	for _, annotation := range []string{PriorityAnnotation, ConfigAnnotation} {
+		oldValue, oldExists := old.Annotations[annotation]
+		newValue, newExists := new.Annotations[annotation]
+		if oldExists != newExists || oldValue != newValue {
+			return true
+		}
+	}
+
+	return !reflect.DeepEqual(old.Spec.Rules, new.Spec.Rules)
+}
+
+// isSource tells whether the controller merges the ingress: a source
+// ingress of the merge ingress class, matching the ingress selector and
+// without ignored annotations.
+func (v *Validator) isSource(ingress *networkingv1.Ingress) bool {
+	if ingress.Annotations[ResultAnnotation] == "true" || getIngressClass(ingress) != v.IngressClass {
+		return false
+	}
+
+	for _, annotation := range v.IngressWatchIgnore {
+		if _, exists := ingress.Annotations[annotation]; exists {
+			return false
+		}
+	}
+
+	return v.IngressSelector == nil || v.IngressSelector.Matches(labels.Set(ingress.Labels))
+}
+
+// listSources lists the source ingresses referencing the config.
+func (v *Validator) listSources(ctx context.Context, ns, configName string) ([]networkingv1.Ingress, error) {
+	ingresses, err := listIndexedIngresses(ctx, v.Client, v.Indexed, ns, v.IngressSelector, configIndexField, configName)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := []networkingv1.Ingress{}
+	for _, ingress := range ingresses {
+		if !v.isSource(&ingress) || ingress.Annotations[ConfigAnnotation] != configName {
+			continue
+		}
+
+		sources = append(sources, ingress)
+	}
+
+	return sources, nil
+}
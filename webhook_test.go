@@ -0,0 +1,314 @@
+package ingress_merge
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newTestValidator(objs []runtime.Object) *Validator {
+	reconciler := newTestReconciler(objs)
+	decoder, _ := admission.NewDecoder(reconciler.Scheme())
+
+	return &Validator{
+		Client:       reconciler.Client,
+		IngressClass: "merge",
+		decoder:      decoder,
+	}
+}
+
+func newAdmissionRequest(t *testing.T, obj runtime.Object) admission.Request {
+	raw, err := json.Marshal(obj)
+	require.NoError(t, err)
+
+	return admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Object: runtime.RawExtension{
+				Raw: raw,
+			},
+		},
+	}
+}
+
+func TestValidateIngress(t *testing.T) {
+	ctx := context.Background()
+	sibling := withAnnotations(newTestIngress("sibling", "foo.example.org", "/"), map[string]string{
+		IngressClassAnnotation: "merge",
+		ConfigAnnotation:       "kubernetes-shared-ingress",
+	})
+	sibling = createdAt(sibling, time.Now().Add(-time.Hour))
+	validator := newTestValidator([]runtime.Object{&sibling})
+
+	tests := []struct {
+		name    string
+		ingress networkingv1.Ingress
+		allowed bool
+	}{
+		{
+			name:    "other ingress class",
+			ingress: withAnnotations(newTestIngress("other", "foo.example.org", "/"), map[string]string{IngressClassAnnotation: "nginx"}),
+			allowed: true,
+		},
+		{
+			name: "invalid priority",
+			ingress: withAnnotations(newTestIngress("my-instance", "bar.example.org", "/"), map[string]string{
+				IngressClassAnnotation: "merge",
+				ConfigAnnotation:       "kubernetes-shared-ingress",
+				PriorityAnnotation:     "high",
+			}),
+			allowed: false,
+		},
+		{
+			name: "missing config annotation",
+			ingress: withAnnotations(newTestIngress("my-instance", "bar.example.org", "/"), map[string]string{
+				IngressClassAnnotation: "merge",
+			}),
+			allowed: false,
+		},
+		{
+			name: "conflicting path",
+			ingress: withAnnotations(newTestIngress("my-instance", "foo.example.org", "/"), map[string]string{
+				IngressClassAnnotation: "merge",
+				ConfigAnnotation:       "kubernetes-shared-ingress",
+			}),
+			allowed: false,
+		},
+		{
+			name: "conflicting path with higher priority",
+			ingress: withAnnotations(newTestIngress("my-instance", "foo.example.org", "/"), map[string]string{
+				IngressClassAnnotation: "merge",
+				ConfigAnnotation:       "kubernetes-shared-ingress",
+				PriorityAnnotation:     "10",
+			}),
+			allowed: true,
+		},
+		{
+			name: "conflicting path of another config",
+			ingress: withAnnotations(newTestIngress("my-instance", "foo.example.org", "/"), map[string]string{
+				IngressClassAnnotation: "merge",
+				ConfigAnnotation:       "other-shared-ingress",
+			}),
+			allowed: true,
+		},
+		{
+			name: "updating the sibling itself",
+			ingress: withAnnotations(newTestIngress("sibling", "foo.example.org", "/"), map[string]string{
+				IngressClassAnnotation: "merge",
+				ConfigAnnotation:       "kubernetes-shared-ingress",
+			}),
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := validator.validateIngress(ctx, newAdmissionRequest(t, &tt.ingress))
+			assert.Equal(t, tt.allowed, response.Allowed, response.Result)
+		})
+	}
+}
+
+func TestValidateIngressSourceSelection(t *testing.T) {
+	ctx := context.Background()
+	annotations := map[string]string{
+		IngressClassAnnotation: "merge",
+		ConfigAnnotation:       "kubernetes-shared-ingress",
+	}
+	newIngress := func(name, shard string, extra map[string]string) networkingv1.Ingress {
+		ingress := withAnnotations(withAnnotations(newTestIngress(name, "foo.example.org", "/"), annotations), extra)
+		ingress.Labels = map[string]string{"shard": shard}
+		return ingress
+	}
+
+	selected := createdAt(newIngress("selected", "a", nil), time.Now().Add(-time.Hour))
+	unselected := createdAt(newIngress("unselected", "b", nil), time.Now().Add(-time.Hour))
+	ignored := createdAt(newIngress("ignored", "a", map[string]string{"example.org/ignore": "true"}), time.Now().Add(-time.Hour))
+
+	validator := newTestValidator([]runtime.Object{&unselected, &ignored})
+	validator.IngressSelector = labels.SelectorFromSet(labels.Set{"shard": "a"})
+	validator.IngressWatchIgnore = []string{"example.org/ignore"}
+
+	// the siblings are not merged by the controller
+	ingress := newIngress("my-instance", "a", nil)
+	response := validator.validateIngress(ctx, newAdmissionRequest(t, &ingress))
+	assert.True(t, response.Allowed, response.Result)
+
+	validator = newTestValidator([]runtime.Object{&selected})
+	validator.IngressSelector = labels.SelectorFromSet(labels.Set{"shard": "a"})
+	validator.IngressWatchIgnore = []string{"example.org/ignore"}
+
+	response = validator.validateIngress(ctx, newAdmissionRequest(t, &ingress))
+	assert.False(t, response.Allowed, response.Result)
+
+	// nor is the validated ingress
+	for _, ingress := range []networkingv1.Ingress{
+		newIngress("my-instance", "b", nil),
+		newIngress("my-instance", "a", map[string]string{"example.org/ignore": "true"}),
+	} {
+		response = validator.validateIngress(ctx, newAdmissionRequest(t, &ingress))
+		assert.True(t, response.Allowed, response.Result)
+	}
+}
+
+func TestValidateIngressUpdate(t *testing.T) {
+	ctx := context.Background()
+	sibling := withAnnotations(newTestIngress("sibling", "foo.example.org", "/"), map[string]string{
+		IngressClassAnnotation: "merge",
+		ConfigAnnotation:       "kubernetes-shared-ingress",
+	})
+	sibling = createdAt(sibling, time.Now().Add(-time.Hour))
+
+	// losing the path conflict against its sibling
+	loser := withAnnotations(newTestIngress("my-instance", "foo.example.org", "/"), map[string]string{
+		IngressClassAnnotation: "merge",
+		ConfigAnnotation:       "kubernetes-shared-ingress",
+	})
+	loser = createdAt(loser, time.Now())
+	missingConfig := withAnnotations(newTestIngress("missing-config", "bar.example.org", "/"), map[string]string{
+		IngressClassAnnotation: "merge",
+	})
+	validator := newTestValidator([]runtime.Object{&sibling, &loser, &missingConfig})
+
+	updateRequest := func(t *testing.T, old, new *networkingv1.Ingress) admission.Request {
+		oldRaw, err := json.Marshal(old)
+		require.NoError(t, err)
+
+		request := newAdmissionRequest(t, new)
+		request.Operation = admissionv1.Update
+		request.OldObject.Raw = oldRaw
+
+		return request
+	}
+
+	t.Run("updates leaving the merge alone", func(t *testing.T) {
+		for _, ingress := range []networkingv1.Ingress{loser, missingConfig} {
+			updated := ingress.DeepCopy()
+			updated.Labels = map[string]string{"team": "payments"}
+			updated.Annotations["external-dns.alpha.kubernetes.io/hostname"] = "foo.example.org"
+
+			response := validator.validateIngress(ctx, updateRequest(t, &ingress, updated))
+			assert.True(t, response.Allowed, response.Result)
+		}
+	})
+
+	t.Run("updates changing the merge", func(t *testing.T) {
+		updated := loser.DeepCopy()
+		updated.Spec.Rules[0].HTTP.Paths[0].Path = "/api"
+		updated.Spec.Rules = append(updated.Spec.Rules, sibling.Spec.Rules...)
+
+		response := validator.validateIngress(ctx, updateRequest(t, &loser, updated))
+		assert.False(t, response.Allowed, response.Result)
+
+		updated = missingConfig.DeepCopy()
+		updated.Annotations[PriorityAnnotation] = "10"
+
+		response = validator.validateIngress(ctx, updateRequest(t, &missingConfig, updated))
+		assert.False(t, response.Allowed, response.Result)
+	})
+
+	t.Run("ingresses being deleted", func(t *testing.T) {
+		for _, ingress := range []networkingv1.Ingress{loser, missingConfig} {
+			old := ingress.DeepCopy()
+			old.Finalizers = []string{"example.org/cleanup"}
+			now := metaV1.Now()
+			old.DeletionTimestamp = &now
+
+			// the finalizer is removed along with a change of the rules
+			updated := old.DeepCopy()
+			updated.Finalizers = nil
+			updated.Spec.Rules = append(updated.Spec.Rules, sibling.Spec.Rules...)
+
+			response := validator.validateIngress(ctx, updateRequest(t, old, updated))
+			assert.True(t, response.Allowed, response.Result)
+		}
+	})
+}
+
+func TestValidateConfigMap(t *testing.T) {
+	ctx := context.Background()
+	source := withAnnotations(newTestIngress("my-instance", "foo.example.org", "/"), map[string]string{
+		IngressClassAnnotation: "merge",
+		ConfigAnnotation:       "kubernetes-shared-ingress",
+	})
+	validator := newTestValidator([]runtime.Object{&source})
+
+	tests := []struct {
+		name      string
+		configMap *corev1.ConfigMap
+		allowed   bool
+	}{
+		{
+			name: "valid",
+			configMap: newTestConfigMap("kubernetes-shared-ingress", map[string]string{
+				AnnotationsConfigKey: `kubernetes.io/ingress.class: alb`,
+			}),
+			allowed: true,
+		},
+		{
+			name: "invalid labels",
+			configMap: newTestConfigMap("kubernetes-shared-ingress", map[string]string{
+				LabelsConfigKey: "{invalid",
+			}),
+			allowed: false,
+		},
+		{
+			name: "ingress class loop",
+			configMap: newTestConfigMap("kubernetes-shared-ingress", map[string]string{
+				AnnotationsConfigKey: `kubernetes.io/ingress.class: merge`,
+			}),
+			allowed: false,
+		},
+		{
+			name: "not referenced",
+			configMap: newTestConfigMap("other", map[string]string{
+				LabelsConfigKey: "{invalid",
+			}),
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := validator.validateConfigMap(ctx, newAdmissionRequest(t, tt.configMap))
+			assert.Equal(t, tt.allowed, response.Allowed, response.Result)
+		})
+	}
+
+	t.Run("updates of an invalid config", func(t *testing.T) {
+		invalid := newTestConfigMap("kubernetes-shared-ingress", map[string]string{
+			LabelsConfigKey: "{invalid",
+		})
+		oldRaw, err := json.Marshal(invalid)
+		require.NoError(t, err)
+
+		annotated := invalid.DeepCopy()
+		annotated.Annotations = map[string]string{StatusAnnotation: "{}"}
+		request := newAdmissionRequest(t, annotated)
+		request.Operation = admissionv1.Update
+		request.OldObject.Raw = oldRaw
+
+		response := validator.validateConfigMap(ctx, request)
+		assert.True(t, response.Allowed, response.Result)
+
+		changed := invalid.DeepCopy()
+		changed.Data[MaxSlotsConfigKey] = "30"
+		request = newAdmissionRequest(t, changed)
+		request.Operation = admissionv1.Update
+		request.OldObject.Raw = oldRaw
+
+		response = validator.validateConfigMap(ctx, request)
+		assert.False(t, response.Allowed, response.Result)
+	})
+}